@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/SkYNewZ/gh-stars-search-engine/internal/engine"
+)
+
+// usage describes the commands, running the server when none is given, formatted with the binary name.
+const usage = `usage: %s [command]
+
+commands:
+  backup <archive>   write a gzip compressed tar archive of the index, "-" for stdout
+  restore <archive>  replace the index with the given archive, "-" for stdin
+
+The server must be stopped while running a command, use the /api/backup endpoint to back up a running server,
+enabled by BACKUP_TOKEN.`
+
+// errUsage is returned when the command line is invalid.
+var errUsage = fmt.Errorf(usage, filepath.Base(os.Args[0]))
+
+// runCommand runs the command of the given command line on the index storage directory.
+func runCommand(ctx context.Context, args []string, storagePath string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+
+	switch args[0] {
+	case "backup":
+		return backup(ctx, storagePath, args[1])
+	case "restore":
+		return restore(storagePath, args[1])
+	default:
+		return errUsage
+	}
+}
+
+// backup writes a backup of the index of the storage directory to the given archive.
+func backup(ctx context.Context, storagePath, archive string) error {
+	if archive == "-" {
+		return engine.BackupStorage(ctx, storagePath, os.Stdout)
+	}
+
+	f, err := os.OpenFile(archive, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+
+	if err := errors.Join(engine.BackupStorage(ctx, storagePath, f), f.Close()); err != nil {
+		return errors.Join(err, os.Remove(archive))
+	}
+
+	return nil
+}
+
+// restore replaces the index of the storage directory with the given archive.
+func restore(storagePath, archive string) error {
+	var r io.Reader = os.Stdin
+	if archive != "-" {
+		f, err := os.Open(archive)
+		if err != nil {
+			return fmt.Errorf("failed to open archive: %w", err)
+		}
+		defer func() { _ = f.Close() }()
+
+		r = f
+	}
+
+	return engine.Restore(r, storagePath)
+}
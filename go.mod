@@ -4,6 +4,7 @@ go 1.21
 
 require (
 	github.com/blevesearch/bleve/v2 v2.4.0
+	github.com/blevesearch/bleve_index_api v1.1.6
 	github.com/google/wire v0.6.0
 	github.com/hasura/go-graphql-client v0.12.1
 	github.com/lmittmann/tint v1.0.4
@@ -12,13 +13,13 @@ require (
 	github.com/robfig/cron/v3 v3.0.1
 	github.com/vburenin/ifacemaker v1.2.1
 	go-simpler.org/sloggen v0.2.0
+	go.etcd.io/bbolt v1.3.7
 	golang.org/x/oauth2 v0.20.0
 )
 
 require (
 	github.com/RoaringBitmap/roaring v1.2.3 // indirect
 	github.com/bits-and-blooms/bitset v1.2.0 // indirect
-	github.com/blevesearch/bleve_index_api v1.1.6 // indirect
 	github.com/blevesearch/geo v0.1.20 // indirect
 	github.com/blevesearch/go-faiss v1.0.13 // indirect
 	github.com/blevesearch/go-porterstemmer v1.0.3 // indirect
@@ -45,7 +46,6 @@ require (
 	github.com/mschoch/smat v0.2.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	go-simpler.org/errorsx v0.8.0 // indirect
-	go.etcd.io/bbolt v1.3.7 // indirect
 	golang.org/x/mod v0.14.0 // indirect
 	golang.org/x/sys v0.17.0 // indirect
 	golang.org/x/tools v0.17.0 // indirect
@@ -0,0 +1,34 @@
+// Package embedding turns texts into vectors whose cosine similarity reflects the similarity of the texts.
+package embedding
+
+import (
+	"context"
+	"math"
+)
+
+// Embedder computes the vectors of texts.
+// Implementations must be safe for concurrent use and return vectors of Dimensions() length.
+type Embedder interface {
+	// Embed returns the vector of each given text, in the same order.
+	Embed(ctx context.Context, texts ...string) ([][]float32, error)
+
+	// Dimensions returns the length of the vectors.
+	Dimensions() int
+}
+
+// Cosine returns the cosine similarity of the given vectors, 0 if one of them is null.
+// Vectors of different lengths are compared on their common dimensions.
+func Cosine(a, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := 0; i < min(len(a), len(b)); i++ {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
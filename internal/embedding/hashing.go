@@ -0,0 +1,124 @@
+package embedding
+
+import (
+	"context"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// DefaultDimensions is the default length of the vectors of the hashing embedder.
+const DefaultDimensions int = 512
+
+// hashingNgramSize is the length of the character n-grams, matching the variants of a word ("diff", "diffing").
+const hashingNgramSize int = 3
+
+// stopWords are the frequent english words carrying no meaning.
+var stopWords = map[string]struct{}{
+	"a": {}, "an": {}, "and": {}, "are": {}, "as": {}, "at": {}, "be": {}, "by": {}, "for": {}, "from": {},
+	"has": {}, "in": {}, "is": {}, "it": {}, "its": {}, "of": {}, "on": {}, "or": {}, "that": {}, "the": {},
+	"this": {}, "to": {}, "was": {}, "with": {}, "you": {}, "your": {}, "can": {}, "will": {}, "into": {},
+}
+
+type hashing struct {
+	dimensions int
+}
+
+// NewHashing returns an offline and deterministic Embedder based on feature hashing.
+// The words, word pairs and character trigrams of the text are hashed into the given number of dimensions,
+// weighted by their log frequency. It captures lexical similarity only, but requires no model nor network access.
+// A non-positive dimensions uses DefaultDimensions.
+func NewHashing(dimensions int) Embedder {
+	if dimensions <= 0 {
+		dimensions = DefaultDimensions
+	}
+
+	return &hashing{dimensions: dimensions}
+}
+
+// Embed returns the vector of each given text.
+func (h *hashing) Embed(ctx context.Context, texts ...string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+	for _, text := range texts {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		vectors = append(vectors, h.embed(text))
+	}
+
+	return vectors, nil
+}
+
+// Dimensions returns the length of the vectors.
+func (h *hashing) Dimensions() int {
+	return h.dimensions
+}
+
+func (h *hashing) embed(text string) []float32 {
+	features := make(map[string]int)
+	words := words(text)
+	for i, word := range words {
+		features["w:"+word]++
+		if i > 0 {
+			features["b:"+words[i-1]+" "+word]++
+		}
+
+		padded := []rune("^" + word + "$")
+		for j := 0; j+hashingNgramSize <= len(padded); j++ {
+			features["g:"+string(padded[j:j+hashingNgramSize])]++
+		}
+	}
+
+	vector := make([]float32, h.dimensions)
+	for feature, count := range features {
+		hash := fnv.New64a()
+		_, _ = hash.Write([]byte(feature))
+		sum := hash.Sum64()
+
+		// the sign bit spreads the collisions around zero
+		weight := 1 + math.Log(float64(count))
+		if sum&(1<<63) != 0 {
+			weight = -weight
+		}
+
+		vector[sum%uint64(h.dimensions)] += float32(weight)
+	}
+
+	normalize(vector)
+	return vector
+}
+
+// words returns the lowercased words of the text, stop words excluded.
+func words(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	out := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if _, ok := stopWords[field]; !ok {
+			out = append(out, field)
+		}
+	}
+
+	return out
+}
+
+// normalize scales the vector to a unit length.
+func normalize(vector []float32) {
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+
+	if norm == 0 {
+		return
+	}
+
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i] = float32(float64(vector[i]) / norm)
+	}
+}
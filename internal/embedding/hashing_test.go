@@ -0,0 +1,67 @@
+package embedding
+
+import (
+	"context"
+	"math"
+	"slices"
+	"testing"
+)
+
+func TestHashingEmbed(t *testing.T) {
+	tests := []struct {
+		name       string
+		dimensions int
+		text       string
+		wantLen    int
+		wantNorm   float64
+	}{
+		{name: "default dimensions", dimensions: 0, text: "A fast JSON parser for Go", wantLen: DefaultDimensions, wantNorm: 1},
+		{name: "custom dimensions", dimensions: 64, text: "A fast JSON parser for Go", wantLen: 64, wantNorm: 1},
+		{name: "stop words only", dimensions: 64, text: "the and of", wantLen: 64, wantNorm: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			first, err := NewHashing(tt.dimensions).Embed(ctx, tt.text)
+			if err != nil {
+				t.Fatalf("Embed() error = %v", err)
+			}
+
+			// another embedder computes the same vector, nothing is learnt nor random
+			second, err := NewHashing(tt.dimensions).Embed(ctx, tt.text)
+			if err != nil {
+				t.Fatalf("Embed() error = %v", err)
+			}
+
+			if !slices.Equal(first[0], second[0]) {
+				t.Errorf("Embed() = %v then %v, want the same vector", first[0], second[0])
+			}
+
+			if len(first[0]) != tt.wantLen {
+				t.Errorf("Embed() length = %d, want %d", len(first[0]), tt.wantLen)
+			}
+
+			var norm float64
+			for _, v := range first[0] {
+				norm += float64(v) * float64(v)
+			}
+
+			if math.Abs(math.Sqrt(norm)-tt.wantNorm) > 1e-6 {
+				t.Errorf("Embed() norm = %f, want %f", math.Sqrt(norm), tt.wantNorm)
+			}
+		})
+	}
+}
+
+func TestHashingSimilarity(t *testing.T) {
+	vectors, err := NewHashing(0).Embed(context.Background(), "json parser", "parsing JSON documents", "static site generator")
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+
+	related, unrelated := Cosine(vectors[0], vectors[1]), Cosine(vectors[0], vectors[2])
+	if related <= unrelated {
+		t.Errorf("Cosine() = %f for related texts and %f for unrelated ones, want the related ones closer", related, unrelated)
+	}
+}
@@ -0,0 +1,141 @@
+package engine
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/blevesearch/bleve/v2/analysis"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/custom"
+	"github.com/blevesearch/bleve/v2/analysis/token/lowercase"
+	"github.com/blevesearch/bleve/v2/analysis/tokenizer/character"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/registry"
+)
+
+const (
+	// CodeAnalyzerName is the name of the analyzer for identifiers, such as repository names or code.
+	// Identifiers are split on "/", "-", "_", "." and camelCase boundaries, the original identifier is kept too:
+	// "hasura/go-graphql-client" is indexed as "hasura/go-graphql-client", "hasura", "go", "graphql" and "client".
+	CodeAnalyzerName = "code"
+
+	codeTokenizerName   = "code"
+	codeSplitFilterName = "code_split"
+)
+
+// codeSeparators are the characters joining the parts of an identifier.
+const codeSeparators = "/-_."
+
+func init() {
+	registry.RegisterTokenizer(codeTokenizerName, func(map[string]any, *registry.Cache) (analysis.Tokenizer, error) {
+		return character.NewCharacterTokenizer(isCodeRune), nil
+	})
+
+	registry.RegisterTokenFilter(codeSplitFilterName, func(map[string]any, *registry.Cache) (analysis.TokenFilter, error) {
+		return &codeSplitFilter{}, nil
+	})
+}
+
+// AddCodeAnalyzer registers the code analyzer in the given index mapping, see CodeAnalyzerName.
+func AddCodeAnalyzer(m *mapping.IndexMappingImpl) error {
+	return m.AddCustomAnalyzer(CodeAnalyzerName, map[string]any{
+		"type":          custom.Name,
+		"tokenizer":     codeTokenizerName,
+		"token_filters": []any{codeSplitFilterName, lowercase.Name},
+	})
+}
+
+// isCodeRune reports whether r is part of an identifier.
+func isCodeRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune(codeSeparators, r)
+}
+
+// codeSplitFilter splits the identifiers into their parts, at the same position as the identifier.
+type codeSplitFilter struct{}
+
+func (f *codeSplitFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	output := make(analysis.TokenStream, 0, len(input))
+	for _, token := range input {
+		// drop the separators around the identifier, such as a sentence final dot
+		term := bytes.TrimLeft(token.Term, codeSeparators)
+		start := token.Start + len(token.Term) - len(term)
+		term = bytes.TrimRight(term, codeSeparators)
+		if len(term) == 0 {
+			continue
+		}
+
+		token.Term, token.Start, token.End = term, start, start+len(term)
+		output = append(output, token)
+
+		parts := splitIdentifier(term)
+		if len(parts) < 2 {
+			continue
+		}
+
+		for _, part := range parts {
+			output = append(output, &analysis.Token{
+				Term:     term[part[0]:part[1]],
+				Start:    start + part[0],
+				End:      start + part[1],
+				Position: token.Position,
+				Type:     token.Type,
+			})
+		}
+	}
+
+	return output
+}
+
+// splitIdentifier returns the byte ranges of the parts of the identifier,
+// separated by codeSeparators and camelCase boundaries ("HTTPServer" is split into "HTTP" and "Server").
+func splitIdentifier(term []byte) [][2]int {
+	parts := make([][2]int, 0)
+	start := -1
+	var prev rune
+	for i := 0; i < len(term); {
+		r, size := utf8.DecodeRune(term[i:])
+		if strings.ContainsRune(codeSeparators, r) {
+			if start >= 0 {
+				parts = append(parts, [2]int{start, i})
+			}
+
+			start, prev = -1, 0
+			i += size
+			continue
+		}
+
+		if start >= 0 && isCamelCaseBoundary(prev, r, term[i+size:]) {
+			parts = append(parts, [2]int{start, i})
+			start = -1
+		}
+
+		if start < 0 {
+			start = i
+		}
+
+		prev = r
+		i += size
+	}
+
+	if start >= 0 {
+		parts = append(parts, [2]int{start, len(term)})
+	}
+
+	return parts
+}
+
+// isCamelCaseBoundary reports whether a new part starts at r, preceded by prev and followed by next.
+func isCamelCaseBoundary(prev, r rune, next []byte) bool {
+	if !unicode.IsUpper(r) {
+		return false
+	}
+
+	if unicode.IsLower(prev) || unicode.IsDigit(prev) {
+		return true // camelCase
+	}
+
+	// end of an acronym followed by a word, such as the S of HTTPServer
+	following, _ := utf8.DecodeRune(next)
+	return unicode.IsUpper(prev) && unicode.IsLower(following)
+}
@@ -0,0 +1,387 @@
+package engine
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/index/scorch"
+	"github.com/blevesearch/bleve/v2/index/upsidedown"
+	"github.com/blevesearch/bleve/v2/index/upsidedown/store/boltdb"
+	bolt "go.etcd.io/bbolt"
+)
+
+// storageOpenTimeout is how long the offline operations wait for the index to be released by a running engine.
+const storageOpenTimeout = time.Second
+
+// indexMetaName and boltStoreName are the files of an index directory holding its type and the boltdb store.
+const (
+	indexMetaName string = "index_meta.json"
+	boltStoreName string = "store"
+)
+
+// restoreDirPattern is the pattern of the directory a backup is extracted to before being served.
+const restoreDirPattern string = "restore-*"
+
+// ErrSnapshotUnsupported is returned when the index storage does not support online copies.
+var ErrSnapshotUnsupported = errors.New("index does not support snapshots")
+
+// Snapshot copies the serving index to the given directory, consistently and without interrupting the searches.
+// The directory must not contain an index.
+func (e *engine) Snapshot(dir string) error {
+	if e.storage.backend != BackendScorch {
+		return fmt.Errorf("%w: %s backend", ErrSnapshotUnsupported, e.storage.backend)
+	}
+
+	e.mu.RLock() // the serving index is not swapped while copied
+	defer e.mu.RUnlock()
+
+	return snapshot(e.serving, dir)
+}
+
+// Backup writes a gzip compressed tar archive of a snapshot of the serving index to w, see Restore.
+func (e *engine) Backup(ctx context.Context, w io.Writer) error {
+	dir, err := os.MkdirTemp("", "ghs-backup-*")
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	if err := e.Snapshot(dir); err != nil {
+		return err
+	}
+
+	return writeArchive(ctx, dir, w)
+}
+
+// BackupStorage writes a gzip compressed tar archive of the serving index of the given storage directory to w.
+// The storage directory must not be used by an engine, see Engine.Backup to back up a running engine.
+func BackupStorage(ctx context.Context, root string, w io.Writer) error {
+	path, err := currentIndexPath(root)
+	if err != nil {
+		return err
+	}
+
+	if path == "" {
+		return fmt.Errorf("failed to open index: no index in %s", root)
+	}
+
+	// checked before opening the index, a boltdb index held by a running engine cannot be opened anyway
+	backend, err := indexBackend(path)
+	if err != nil {
+		return err
+	}
+
+	if backend != BackendScorch {
+		return fmt.Errorf("%w: %s backend", ErrSnapshotUnsupported, backend)
+	}
+
+	index, err := openStorageIndex(path, backend)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = index.Close() }()
+
+	dir, err := os.MkdirTemp("", "ghs-backup-*")
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	if err := snapshot(index, dir); err != nil {
+		return err
+	}
+
+	return writeArchive(ctx, dir, w)
+}
+
+// Restore extracts the backup archive read from r, see Engine.Backup, and makes it the serving index
+// of the given storage directory. The indexes of the storage directory are removed.
+// The storage directory must not be used by an engine.
+// When the backup has been built with another mapping, the index is rebuilt by the next engine, see New.
+func Restore(r io.Reader, root string) error {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	dir, err := os.MkdirTemp(root, restoreDirPattern)
+	if err != nil {
+		return fmt.Errorf("failed to create restore directory: %w", err)
+	}
+
+	if err := restore(r, root, dir); err != nil {
+		return errors.Join(err, os.RemoveAll(dir))
+	}
+
+	return nil
+}
+
+// restore extracts the backup archive into dir then replaces the indexes of the storage directory with it.
+func restore(r io.Reader, root, dir string) error {
+	// the indexes must not be removed while served
+	if err := checkStorageIndex(root); err != nil {
+		return err
+	}
+
+	if err := readArchive(r, dir); err != nil {
+		return err
+	}
+
+	index, err := bleve.Open(dir)
+	if err != nil {
+		return fmt.Errorf("invalid backup: %w", err)
+	}
+
+	version, err := index.GetInternal([]byte(mappingVersionKey))
+	if err := errors.Join(err, index.Close()); err != nil {
+		return fmt.Errorf("invalid backup: %w", err)
+	}
+
+	if err := removeIndexes(root); err != nil {
+		return fmt.Errorf("failed to remove indexes: %w", err)
+	}
+
+	// a backup of an index created before the mapping versions is rebuilt
+	name := string(version)
+	if name == "" {
+		name = "legacy"
+	}
+
+	path := filepath.Join(root, indexDirPrefix+name)
+	if err := os.Rename(dir, path); err != nil {
+		return fmt.Errorf("failed to move restored index: %w", err)
+	}
+
+	return setCurrentIndexPath(root, path)
+}
+
+// removeIndexes removes every index of the storage directory, the legacy one included.
+func removeIndexes(root string) error {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return err
+	}
+
+	errs := []error{removeIndex(root, root)}
+	if err := os.Remove(filepath.Join(root, currentFileName)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		errs = append(errs, err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() && strings.HasPrefix(entry.Name(), indexDirPrefix) {
+			errs = append(errs, os.RemoveAll(filepath.Join(root, entry.Name())))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// checkStorageIndex fails if the serving index of the storage directory is opened by a running engine.
+func checkStorageIndex(root string) error {
+	path, err := currentIndexPath(root)
+	if err != nil || path == "" {
+		return err
+	}
+
+	backend, err := indexBackend(path)
+	if err != nil {
+		return err
+	}
+
+	index, err := openStorageIndex(path, backend)
+	if err != nil {
+		return err
+	}
+
+	if err := index.Close(); err != nil {
+		return fmt.Errorf("failed to close index: %w", err)
+	}
+
+	return nil
+}
+
+// openStorageIndex opens the index at the given path read-only.
+// Fails if the index is opened by a running engine.
+func openStorageIndex(path string, backend Backend) (bleve.Index, error) {
+	// the boltdb store ignores bolt_timeout and would wait forever for a running engine to release its lock
+	if backend == BackendBoltDB {
+		db, err := bolt.Open(filepath.Join(path, boltStoreName), 0o600, &bolt.Options{ReadOnly: true, Timeout: storageOpenTimeout})
+		if err != nil {
+			return nil, fmt.Errorf("failed to open index %s, it may be used by a running server: %w", path, err)
+		}
+
+		if err := db.Close(); err != nil {
+			return nil, fmt.Errorf("failed to close index store: %w", err)
+		}
+	}
+
+	index, err := bleve.OpenUsing(path, map[string]any{
+		"read_only":    true,
+		"bolt_timeout": storageOpenTimeout.String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open index %s, it may be used by a running server: %w", path, err)
+	}
+
+	return index, nil
+}
+
+// indexBackend returns the backend of the index at the given path, read from its metadata without opening it.
+func indexBackend(path string) (Backend, error) {
+	data, err := os.ReadFile(filepath.Join(path, indexMetaName))
+	if err != nil {
+		return "", fmt.Errorf("failed to read index metadata: %w", err)
+	}
+
+	var meta struct {
+		IndexType string `json:"index_type"`
+		Storage   string `json:"storage"`
+	}
+
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return "", fmt.Errorf("failed to decode index metadata: %w", err)
+	}
+
+	switch {
+	case meta.IndexType == scorch.Name:
+		return BackendScorch, nil
+	case meta.IndexType == upsidedown.Name && meta.Storage == boltdb.Name:
+		return BackendBoltDB, nil
+	default:
+		return "", fmt.Errorf("%w: %s index with %s storage", ErrInvalidBackend, meta.IndexType, meta.Storage)
+	}
+}
+
+// snapshot copies the given index to the given directory.
+func snapshot(index bleve.Index, dir string) error {
+	copyable, ok := index.(bleve.IndexCopyable)
+	if !ok {
+		return ErrSnapshotUnsupported
+	}
+
+	if err := copyable.CopyTo(bleve.FileSystemDirectory(dir)); err != nil {
+		return fmt.Errorf("failed to copy index: %w", err)
+	}
+
+	return nil
+}
+
+// writeArchive writes the files of the given directory to w as a gzip compressed tar archive.
+func writeArchive(ctx context.Context, dir string, w io.Writer) error {
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		name, err := filepath.Rel(dir, path)
+		if err != nil || name == "." {
+			return err
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+
+		header.Name = filepath.ToSlash(name)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+
+		_, err = io.Copy(tw, f)
+		return err
+	})
+
+	if err := errors.Join(err, tw.Close(), zw.Close()); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	return nil
+}
+
+// readArchive extracts the gzip compressed tar archive read from r into the given directory.
+func readArchive(r io.Reader, dir string) error {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+
+	tr := tar.NewReader(zr)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to read backup: %w", err)
+		}
+
+		// refuse the entries escaping the directory
+		name := filepath.FromSlash(header.Name)
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("failed to read backup: invalid file %s", header.Name)
+		}
+
+		path := filepath.Join(dir, name)
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(path, 0o755)
+		case tar.TypeReg:
+			err = extractFile(tr, path)
+		default:
+			err = fmt.Errorf("unsupported file type %c", header.Typeflag)
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to extract %s: %w", header.Name, err)
+		}
+	}
+}
+
+// extractFile writes the content read from r to the file at the given path.
+func extractFile(r io.Reader, path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, r)
+	return errors.Join(err, f.Close())
+}
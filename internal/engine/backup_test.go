@@ -0,0 +1,177 @@
+package engine
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/mapping"
+)
+
+// backupTestEngine returns the backup archive of an index built with the given mapping and holding the given documents.
+func backupTestEngine(t *testing.T, m mapping.IndexMapping, ids ...string) []byte {
+	t.Helper()
+
+	e := openTestEngine(t, t.TempDir(), m)
+	docs := make([]Indexable, 0, len(ids))
+	for _, id := range ids {
+		docs = append(docs, &testDocument{ID: id, Description: "backed up"})
+	}
+
+	if err := e.BatchIndex(docs, 10); err != nil {
+		t.Fatalf("BatchIndex() error = %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := e.Backup(context.Background(), &buf); err != nil {
+		t.Fatalf("Backup() error = %v", err)
+	}
+
+	closeTestEngine(e)
+	return buf.Bytes()
+}
+
+// testArchive is a gzip compressed tar archive of the given entries.
+func testArchive(t *testing.T, headers ...*tar.Header) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(zw)
+	for _, header := range headers {
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatalf("WriteHeader() error = %v", err)
+		}
+
+		if _, err := tw.Write(make([]byte, header.Size)); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	if err := errors.Join(tw.Close(), zw.Close()); err != nil {
+		t.Fatalf("failed to write archive: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestRestore(t *testing.T) {
+	archive := backupTestEngine(t, nil, "1", "2")
+
+	// the restored index replaces the existing one
+	root := t.TempDir()
+	e := newTestEngine(t, root)
+	if err := e.BatchIndex([]Indexable{&testDocument{ID: "3", Description: "replaced"}}, 10); err != nil {
+		t.Fatalf("BatchIndex() error = %v", err)
+	}
+
+	closeTestEngine(e)
+	if err := Restore(bytes.NewReader(archive), root); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+
+	if got, want := currentIndexDir(t, root), indexDir(t, bleve.NewIndexMapping()); got != want {
+		t.Errorf("%s = %s, want %s", currentFileName, got, want)
+	}
+
+	e = newTestEngine(t, root)
+	if e.Rebuilding() {
+		t.Error("Rebuilding() = true, want false for a backup of the same mapping")
+	}
+
+	if ids := engineIDs(t, e); !slices.Equal(ids, []string{"1", "2"}) {
+		t.Errorf("IDs() = %v, want [1 2] from the backup", ids)
+	}
+}
+
+func TestRestoreMappingMismatch(t *testing.T) {
+	oldMapping := changedMapping()
+	archive := backupTestEngine(t, oldMapping, "1")
+
+	root := t.TempDir()
+	if err := Restore(bytes.NewReader(archive), root); err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+
+	// the restored index keeps the version of its mapping, it serves until rebuilt with the current one
+	if got, want := currentIndexDir(t, root), indexDir(t, oldMapping); got != want {
+		t.Errorf("%s = %s, want %s", currentFileName, got, want)
+	}
+
+	e := newTestEngine(t, root)
+	if !e.Rebuilding() {
+		t.Error("Rebuilding() = false, want true for a backup of another mapping")
+	}
+
+	if ids := engineIDs(t, e); !slices.Equal(ids, []string{"1"}) {
+		t.Errorf("IDs() = %v, want [1] from the backup", ids)
+	}
+}
+
+func TestRestoreInvalidArchive(t *testing.T) {
+	tests := []struct {
+		name    string
+		archive func(t *testing.T) []byte
+	}{
+		{
+			name:    "not gzip",
+			archive: func(*testing.T) []byte { return []byte("not an archive") },
+		},
+		{
+			name: "parent traversal",
+			archive: func(t *testing.T) []byte {
+				return testArchive(t, &tar.Header{Name: "../evil", Typeflag: tar.TypeReg, Mode: 0o600, Size: 4})
+			},
+		},
+		{
+			name: "nested traversal",
+			archive: func(t *testing.T) []byte {
+				return testArchive(t, &tar.Header{Name: "store/../../evil", Typeflag: tar.TypeReg, Mode: 0o600, Size: 4})
+			},
+		},
+		{
+			name: "absolute path",
+			archive: func(t *testing.T) []byte {
+				return testArchive(t, &tar.Header{Name: "/evil", Typeflag: tar.TypeReg, Mode: 0o600, Size: 4})
+			},
+		},
+		{
+			name: "symlink",
+			archive: func(t *testing.T) []byte {
+				return testArchive(t, &tar.Header{Name: "evil", Typeflag: tar.TypeSymlink, Linkname: "../evil"})
+			},
+		},
+		{
+			name: "not an index",
+			archive: func(t *testing.T) []byte {
+				return testArchive(t, &tar.Header{Name: "evil", Typeflag: tar.TypeReg, Mode: 0o600, Size: 4})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent := t.TempDir()
+			root := filepath.Join(parent, "storage")
+			if err := Restore(bytes.NewReader(tt.archive(t)), root); err == nil {
+				t.Fatal("Restore() error = nil, want an error")
+			}
+
+			if _, err := os.Lstat(filepath.Join(parent, "evil")); !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("file outside the storage directory stat error = %v, want none written", err)
+			}
+
+			// the extracted files are removed
+			if entries, err := os.ReadDir(root); err != nil || len(entries) != 0 {
+				t.Errorf("storage directory entries = %v (error %v), want none", entries, err)
+			}
+		})
+	}
+}
@@ -2,29 +2,57 @@ package engine
 
 import (
 	"context"
-	"errors"
 	"fmt"
 	"log/slog"
+	"sync"
+	"time"
 
 	"github.com/blevesearch/bleve/v2"
 	"github.com/blevesearch/bleve/v2/mapping"
+	_ "github.com/blevesearch/bleve/v2/search/highlight/highlighter/ansi" // register the ansi highlighter
+	"github.com/blevesearch/bleve/v2/search/query"
+
+	"github.com/SkYNewZ/gh-stars-search-engine/internal/embedding"
 )
 
 type Indexable interface {
 	GetID() string
 }
 
-//go:generate go run github.com/vburenin/ifacemaker --file $GOFILE --struct engine --iface Engine --pkg engine --output engine_iface.go
+//go:generate go run github.com/vburenin/ifacemaker --file $GOFILE --file suggest.go --file similar.go --file rebuild.go --file backup.go --file stats.go --struct engine --iface Engine --pkg engine --output engine_iface.go
 type engine struct {
-	index  bleve.Index
-	logger *slog.Logger
+	index     bleve.IndexAlias // serving index, swapped once rebuilt
+	logger    *slog.Logger
+	embedder  embedding.Embedder
+	relevance Relevance
+	analyzers map[string]string // analyzer of the mapped fields, by field name, see fieldAnalyzers
+
+	root    string // storage directory, empty in memory
+	storage storage
+	mu      sync.RWMutex // guards the indexes while swapping
+	serving bleve.Index
+	next    bleve.Index // index being rebuilt with the current mapping, nil if up-to-date
+
+	vectorsMu sync.RWMutex
+	vectors   map[string][]float32 // vectors by document ID, nil until loaded
 }
 
-// New returns a new search Engine.
-func New(path string, logger *slog.Logger, mapper mapping.IndexMapping) (Engine, error) {
-	var index bleve.Index
-	var err error
+// Option is an engine option.
+type Option func(*engine)
+
+// WithEmbedder sets the Embedder computing the vectors of the Embeddable documents and of the hybrid queries.
+// Defaults to the offline hashing embedder.
+func WithEmbedder(embedder embedding.Embedder) Option {
+	return func(e *engine) {
+		e.embedder = embedder
+	}
+}
 
+// New returns a new search Engine storing its index in the given directory.
+// The index carries the version of its mapping: when the given mapping differs, the index is rebuilt
+// in a new directory while the old one keeps serving, see Rebuilding and CompleteRebuild.
+// The index is stored with the scorch backend by default, see WithBackend and WithMemoryStorage.
+func New(path string, logger *slog.Logger, mapper mapping.IndexMapping, opts ...Option) (Engine, error) {
 	// use default logger if none is provided
 	if logger == nil {
 		logger = slog.Default()
@@ -35,35 +63,90 @@ func New(path string, logger *slog.Logger, mapper mapping.IndexMapping) (Engine,
 		mapper = bleve.NewIndexMapping()
 	}
 
-	index, err = bleve.New(path, mapper)
-	if err != nil && errors.Is(err, bleve.ErrorIndexPathExists) {
-		index, err = bleve.Open(path)
+	e := &engine{
+		logger:    logger,
+		embedder:  embedding.NewHashing(embedding.DefaultDimensions),
+		relevance: DefaultRelevance,
+		analyzers: fieldAnalyzers(mapper),
+		root:      path,
+		storage:   storage{backend: BackendScorch},
+	}
+
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	if err := e.storage.validate(); err != nil {
+		return nil, err
 	}
 
+	if e.storage.memory {
+		serving, err := e.storage.create("", mapper)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create index: %w", err)
+		}
+
+		e.serving, e.root = serving, ""
+		e.index = bleve.NewIndexAlias(serving)
+		return e, nil
+	}
+
+	version, err := MappingVersion(mapper)
+	if err != nil {
+		return nil, err
+	}
+
+	serving, next, err := openIndexes(path, mapper, e.storage.version(version), &e.storage)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open index: %w", err)
 	}
 
-	return &engine{
-		index:  index,
-		logger: logger,
-	}, nil
+	if next != nil {
+		logger.Warn(fmt.Sprintf("index mapping or storage changed, rebuilding index in %s", next.Name()))
+	}
+
+	e.serving, e.next = serving, next
+	e.index = bleve.NewIndexAlias(serving)
+	return e, nil
 }
 
 // BatchIndex indexes the given data in batches of the given size.
+// The vectors of the Embeddable data are stored along with them.
 func (e *engine) BatchIndex(data []Indexable, batchSize int) error {
-	batch := e.index.NewBatch() // init the first batch
+	indexes := e.writeIndexes()
+	batches := make([]*bleve.Batch, len(indexes))
+	newBatches := func() {
+		for i, index := range indexes {
+			batches[i] = index.NewBatch()
+		}
+	}
+
+	newBatches() // init the first batch
 	batchCount := 0
+	embeddables := make([]Embeddable, 0)
 
 	flushBatch := func() error {
 		e.logger.Debug(fmt.Sprintf("indexing batch (%d docs)", batchCount))
 
-		if err := e.index.Batch(batch); err != nil {
-			return fmt.Errorf("failed to index batch: %w", err)
+		vectors, err := e.embedBatch(context.Background(), embeddables)
+		if err != nil {
+			return err
+		}
+
+		for i, index := range indexes {
+			for id, vector := range vectors {
+				batches[i].SetInternal([]byte(vectorKeyPrefix+id), encodeVector(vector))
+			}
+
+			if err := index.Batch(batches[i]); err != nil {
+				return fmt.Errorf("failed to index batch: %w", err)
+			}
 		}
 
-		batch = e.index.NewBatch()
+		e.setVectors(vectors)
+		newBatches()
 		batchCount = 0
+		embeddables = embeddables[:0]
 
 		return nil
 	}
@@ -73,11 +156,17 @@ func (e *engine) BatchIndex(data []Indexable, batchSize int) error {
 		e.logger.Debug(fmt.Sprintf("indexing %s", d.GetID()))
 
 		// add the document to the batch
-		if err := batch.Index(d.GetID(), d); err != nil {
-			return fmt.Errorf("failed to index document %s: %w", d.GetID(), err)
+		for _, batch := range batches {
+			if err := batch.Index(d.GetID(), d); err != nil {
+				return fmt.Errorf("failed to index document %s: %w", d.GetID(), err)
+			}
 		}
 		batchCount++
 
+		if embeddable, ok := d.(Embeddable); ok {
+			embeddables = append(embeddables, embeddable)
+		}
+
 		// flush the batch if it's full
 		if batchCount >= batchSize {
 			if err := flushBatch(); err != nil {
@@ -90,6 +179,49 @@ func (e *engine) BatchIndex(data []Indexable, batchSize int) error {
 	return flushBatch()
 }
 
+// Delete removes the documents with the given IDs from the index.
+func (e *engine) Delete(ids ...string) error {
+	vectors := make(map[string][]float32, len(ids))
+	for _, index := range e.writeIndexes() {
+		batch := index.NewBatch()
+		for _, id := range ids {
+			e.logger.Debug(fmt.Sprintf("deleting %s", id))
+			batch.Delete(id)
+			batch.DeleteInternal([]byte(vectorKeyPrefix + id))
+			vectors[id] = nil
+		}
+
+		if err := index.Batch(batch); err != nil {
+			return fmt.Errorf("failed to delete documents: %w", err)
+		}
+	}
+
+	e.setVectors(vectors)
+
+	return nil
+}
+
+// IDs returns the IDs of all the indexed documents.
+func (e *engine) IDs(ctx context.Context) ([]string, error) {
+	count, err := e.index.DocCount()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count documents: %w", err)
+	}
+
+	search := bleve.NewSearchRequestOptions(bleve.NewMatchAllQuery(), int(count), 0, false)
+	results, err := e.index.SearchInContext(ctx, search)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list documents: %w", err)
+	}
+
+	ids := make([]string, 0, len(results.Hits))
+	for _, hit := range results.Hits {
+		ids = append(ids, hit.ID)
+	}
+
+	return ids, nil
+}
+
 type SearchOption func(*bleve.SearchRequest)
 
 // WithSearchFields sets the fields to return in the search results.
@@ -113,17 +245,75 @@ func WithSearchSize(size int) SearchOption {
 	}
 }
 
+// WithSearchSort sets the fields to sort the results by, in order of priority.
+// A field prefixed with "-" is sorted in descending order, "-_score" sorts the most relevant first
+// while "_score" sorts the least relevant first.
+// See https://blevesearch.com/docs/Sorting/
+func WithSearchSort(fields ...string) SearchOption {
+	return func(r *bleve.SearchRequest) {
+		r.SortBy(fields)
+	}
+}
+
+// WithSearchFilter restricts the results to the documents having the given term in the given field.
+func WithSearchFilter(field, term string) SearchOption {
+	return func(r *bleve.SearchRequest) {
+		filter := bleve.NewTermQuery(term)
+		filter.SetField(field)
+		r.Query = bleve.NewConjunctionQuery(r.Query, filter)
+	}
+}
+
+// WithSearchBoolFilter restricts the results to the documents having the given value in the given boolean field.
+func WithSearchBoolFilter(field string, value bool) SearchOption {
+	return func(r *bleve.SearchRequest) {
+		filter := bleve.NewBoolFieldQuery(value)
+		filter.SetField(field)
+		r.Query = bleve.NewConjunctionQuery(r.Query, filter)
+	}
+}
+
+// WithSearchHighlight highlights the matches in the given fields using the given style ("html" or "ansi").
+// The fields must be stored in the index to generate the fragments.
+func WithSearchHighlight(style string, fields ...string) SearchOption {
+	return func(r *bleve.SearchRequest) {
+		r.Highlight = bleve.NewHighlightWithStyle(style)
+		for _, field := range fields {
+			r.Highlight.AddField(field)
+		}
+	}
+}
+
 // Search executes the given query and returns the results.
-// TODO: if we want to sort by starredAt, we need to index it as a date field and use sort https://blevesearch.com/docs/Sorting/
-func (e *engine) Search(ctx context.Context, q string, opts ...SearchOption) (*bleve.SearchResult, error) {
-	// https://blevesearch.com/docs/Query-String-Query/
-	query := bleve.NewQueryStringQuery(q)
+// See NewQuery to build the query from a search text.
+// The documents matching a TextQuery or a HybridQuery are ranked according to the engine Relevance.
+// A HybridQuery ranks the results by a fusion of the keyword and vector scores, ignoring the requested sort.
+func (e *engine) Search(ctx context.Context, q query.Query, opts ...SearchOption) (*bleve.SearchResult, error) {
+	if text, ok := q.(*TextQuery); ok {
+		q = e.relevance.boost(text.fieldQuery(e.relevance.FieldBoosts, e.analyzers), text.Text, time.Now(), e.analyzers)
+	}
+
+	// the vector candidates are added before the options restrict the query
+	var sims map[string]float64
+	hybrid, isHybrid := q.(*HybridQuery)
+	if isHybrid {
+		var err error
+		if q, sims, err = e.hybridQuery(ctx, hybrid); err != nil {
+			return nil, err
+		}
+
+		q = e.relevance.boost(q, hybrid.Text, time.Now(), e.analyzers)
+	}
 
-	search := bleve.NewSearchRequest(query)
+	search := bleve.NewSearchRequest(q)
 	for _, opt := range opts {
 		opt(search)
 	}
 
+	if isHybrid {
+		return e.hybridSearch(ctx, search, sims, hybrid.VectorWeight)
+	}
+
 	results, err := e.index.SearchInContext(ctx, search)
 	if err != nil {
 		return nil, fmt.Errorf("failed to search: %w", err)
@@ -131,3 +321,32 @@ func (e *engine) Search(ctx context.Context, q string, opts ...SearchOption) (*b
 
 	return results, nil
 }
+
+// GetMetadata returns the value stored in the index internal storage for the given key.
+// Returns nil if the key does not exist.
+func (e *engine) GetMetadata(key string) ([]byte, error) {
+	value, err := e.metadataIndex().GetInternal([]byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get metadata %s: %w", key, err)
+	}
+
+	return value, nil
+}
+
+// SetMetadata stores the given value in the index internal storage for the given key.
+func (e *engine) SetMetadata(key string, value []byte) error {
+	if err := e.metadataIndex().SetInternal([]byte(key), value); err != nil {
+		return fmt.Errorf("failed to set metadata %s: %w", key, err)
+	}
+
+	return nil
+}
+
+// DeleteMetadata removes the value stored in the index internal storage for the given key.
+func (e *engine) DeleteMetadata(key string) error {
+	if err := e.metadataIndex().DeleteInternal([]byte(key)); err != nil {
+		return fmt.Errorf("failed to delete metadata %s: %w", key, err)
+	}
+
+	return nil
+}
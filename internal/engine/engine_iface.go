@@ -4,15 +4,58 @@ package engine
 
 import (
 	"context"
+	"io"
 
 	"github.com/blevesearch/bleve/v2"
+	_ "github.com/blevesearch/bleve/v2/search/highlight/highlighter/ansi"
+	"github.com/blevesearch/bleve/v2/search/query"
 )
 
 // Engine ...
 type Engine interface {
 	// BatchIndex indexes the given data in batches of the given size.
+	// The vectors of the Embeddable data are stored along with them.
 	BatchIndex(data []Indexable, batchSize int) error
+	// Delete removes the documents with the given IDs from the index.
+	Delete(ids ...string) error
+	// IDs returns the IDs of all the indexed documents.
+	IDs(ctx context.Context) ([]string, error)
 	// Search executes the given query and returns the results.
-	// TODO: if we want to sort by starredAt, we need to index it as a date field and use sort https://blevesearch.com/docs/Sorting/
-	Search(ctx context.Context, q string, opts ...SearchOption) (*bleve.SearchResult, error)
+	// See NewQuery to build the query from a search text.
+	// The documents matching a TextQuery or a HybridQuery are ranked according to the engine Relevance.
+	// A HybridQuery ranks the results by a fusion of the keyword and vector scores, ignoring the requested sort.
+	Search(ctx context.Context, q query.Query, opts ...SearchOption) (*bleve.SearchResult, error)
+	// GetMetadata returns the value stored in the index internal storage for the given key.
+	// Returns nil if the key does not exist.
+	GetMetadata(key string) ([]byte, error)
+	// SetMetadata stores the given value in the index internal storage for the given key.
+	SetMetadata(key string, value []byte) error
+	// DeleteMetadata removes the value stored in the index internal storage for the given key.
+	DeleteMetadata(key string) error
+	// Suggest returns the indexed terms of the given fields starting with the given prefix.
+	// The prefix is lowercased, the fields are expected to be lowercased by their analyzer.
+	// Terms are ranked by number of documents, then the shortest first, then by term and field.
+	// At most size suggestions are returned.
+	Suggest(ctx context.Context, prefix string, size int, fields ...string) ([]*Suggestion, error)
+	// Similar returns the documents similar to the document having the given ID, the most similar first.
+	// The query is built from the most significant terms of the document description, readme and topics,
+	// weighted by their TF-IDF over the index. The document itself is excluded from the results.
+	Similar(ctx context.Context, id string, opts ...SearchOption) (*bleve.SearchResult, error)
+	// Rebuilding reports whether the index is being rebuilt because its mapping changed.
+	// While rebuilding, the documents are indexed in both indexes and the metadata are read and written
+	// in the rebuilt index only, so that the indexer fetches everything again. Searches use the old index.
+	Rebuilding() bool
+	// CompleteRebuild makes the rebuilt index the serving one and removes the old index.
+	// It must only be called once every document has been indexed again.
+	CompleteRebuild() error
+	// Snapshot copies the serving index to the given directory, consistently and without interrupting the searches.
+	// The directory must not contain an index.
+	Snapshot(dir string) error
+	// Backup writes a gzip compressed tar archive of a snapshot of the serving index to w, see Restore.
+	Backup(ctx context.Context, w io.Writer) error
+	// DocCount returns the number of documents of the serving index, without walking the storage directory.
+	DocCount() (uint64, error)
+	// Stats returns the statistics of the serving index.
+	// While rebuilding, the disk size includes the rebuilt index.
+	Stats(ctx context.Context) (*Stats, error)
 }
@@ -0,0 +1,199 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/mapping"
+)
+
+// testDocument is a document indexed by the tests.
+type testDocument struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+}
+
+func (d *testDocument) GetID() string {
+	return d.ID
+}
+
+// newTestEngine returns an engine with the given options, its indexes are closed with the test.
+func newTestEngine(t *testing.T, path string, opts ...Option) *engine {
+	t.Helper()
+
+	return openTestEngine(t, path, nil, opts...)
+}
+
+// openTestEngine returns an engine with the given mapping and options, its indexes are closed with the test.
+func openTestEngine(t *testing.T, path string, m mapping.IndexMapping, opts ...Option) *engine {
+	t.Helper()
+
+	e, err := New(path, nil, m, opts...)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	impl := e.(*engine)
+	t.Cleanup(func() { closeTestEngine(impl) })
+	return impl
+}
+
+// closeTestEngine closes the indexes of the engine once, the alias does not close them.
+func closeTestEngine(e *engine) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for _, index := range []bleve.Index{e.serving, e.next} {
+		if index != nil {
+			_ = index.Close()
+		}
+	}
+
+	e.serving, e.next = nil, nil
+}
+
+func TestEngine(t *testing.T) {
+	tests := []struct {
+		name        string
+		memory      bool
+		opts        []Option
+		snapshotErr error
+	}{
+		{name: "scorch"},
+		{name: "boltdb", opts: []Option{WithBackend(BackendBoltDB)}, snapshotErr: ErrSnapshotUnsupported},
+		{name: "memory", memory: true, opts: []Option{WithMemoryStorage()}},
+		{name: "boltdb memory", memory: true, opts: []Option{WithBackend(BackendBoltDB), WithMemoryStorage()}, snapshotErr: ErrSnapshotUnsupported},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			e := newTestEngine(t, t.TempDir(), tt.opts...)
+
+			docs := []Indexable{
+				&testDocument{ID: "1", Description: "full text search engine"},
+				&testDocument{ID: "2", Description: "static site generator"},
+			}
+
+			if err := e.BatchIndex(docs, 10); err != nil {
+				t.Fatalf("BatchIndex() error = %v", err)
+			}
+
+			search := func(text string) []string {
+				t.Helper()
+
+				q, err := NewQuery(text)
+				if err != nil {
+					t.Fatalf("NewQuery(%q) error = %v", text, err)
+				}
+
+				results, err := e.Search(ctx, q)
+				if err != nil {
+					t.Fatalf("Search(%q) error = %v", text, err)
+				}
+
+				ids := make([]string, 0, len(results.Hits))
+				for _, hit := range results.Hits {
+					ids = append(ids, hit.ID)
+				}
+
+				return ids
+			}
+
+			if got := search("search"); !slices.Equal(got, []string{"1"}) {
+				t.Errorf("Search() = %v, want [1]", got)
+			}
+
+			if err := e.Delete("1"); err != nil {
+				t.Fatalf("Delete() error = %v", err)
+			}
+
+			if got := search("search"); len(got) != 0 {
+				t.Errorf("Search() after Delete() = %v, want none", got)
+			}
+
+			ids, err := e.IDs(ctx)
+			if err != nil {
+				t.Fatalf("IDs() error = %v", err)
+			}
+
+			if !slices.Equal(ids, []string{"2"}) {
+				t.Errorf("IDs() = %v, want [2]", ids)
+			}
+
+			if err := e.SetMetadata("key", []byte("value")); err != nil {
+				t.Fatalf("SetMetadata() error = %v", err)
+			}
+
+			value, err := e.GetMetadata("key")
+			if err != nil || !bytes.Equal(value, []byte("value")) {
+				t.Errorf("GetMetadata() = %q, %v, want %q", value, err, "value")
+			}
+
+			if err := e.DeleteMetadata("key"); err != nil {
+				t.Fatalf("DeleteMetadata() error = %v", err)
+			}
+
+			if value, err := e.GetMetadata("key"); err != nil || value != nil {
+				t.Errorf("GetMetadata() after DeleteMetadata() = %q, %v, want nil", value, err)
+			}
+
+			stats, err := e.Stats(ctx)
+			if err != nil {
+				t.Fatalf("Stats() error = %v", err)
+			}
+
+			if stats.Documents != 1 || stats.InMemory != tt.memory || stats.InMemory != (stats.DiskSize == 0) {
+				t.Errorf("Stats() = %+v, want 1 document and in memory %t", stats, tt.memory)
+			}
+
+			if err := e.Snapshot(t.TempDir()); !errors.Is(err, tt.snapshotErr) {
+				t.Errorf("Snapshot() error = %v, want %v", err, tt.snapshotErr)
+			}
+
+			if err := e.Backup(ctx, io.Discard); !errors.Is(err, tt.snapshotErr) {
+				t.Errorf("Backup() error = %v, want %v", err, tt.snapshotErr)
+			}
+		})
+	}
+}
+
+func TestNewInvalidBackend(t *testing.T) {
+	if _, err := New(t.TempDir(), nil, nil, WithBackend("leveldb")); !errors.Is(err, ErrInvalidBackend) {
+		t.Errorf("New() error = %v, want %v", err, ErrInvalidBackend)
+	}
+}
+
+func TestBackupStorageBoltDB(t *testing.T) {
+	root := t.TempDir()
+	e := newTestEngine(t, root, WithBackend(BackendBoltDB))
+	if err := e.BatchIndex([]Indexable{&testDocument{ID: "1", Description: "full text search engine"}}, 10); err != nil {
+		t.Fatalf("BatchIndex() error = %v", err)
+	}
+
+	// the boltdb store of the running engine is locked, the offline operations must not wait for it
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		if err := BackupStorage(context.Background(), root, io.Discard); !errors.Is(err, ErrSnapshotUnsupported) {
+			t.Errorf("BackupStorage() error = %v, want %v", err, ErrSnapshotUnsupported)
+		}
+
+		if err := Restore(bytes.NewReader(nil), root); err == nil {
+			t.Error("Restore() error = nil, want the index to be in use")
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * storageOpenTimeout):
+		t.Fatal("BackupStorage() and Restore() are waiting for the index used by the engine")
+	}
+}
@@ -0,0 +1,54 @@
+package engine
+
+import (
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+)
+
+// NumericRange is a named bucket of a numeric range facet.
+// A nil bound is open.
+type NumericRange struct {
+	Name string
+	Min  *float64
+	Max  *float64
+}
+
+// DateRange is a named bucket of a date range facet.
+// A zero bound is open.
+type DateRange struct {
+	Name  string
+	Start time.Time
+	End   time.Time
+}
+
+// WithSearchTermFacet adds a facet counting the size most frequent terms of the given field.
+func WithSearchTermFacet(name, field string, size int) SearchOption {
+	return func(r *bleve.SearchRequest) {
+		r.AddFacet(name, bleve.NewFacetRequest(field, size))
+	}
+}
+
+// WithSearchNumericRangeFacet adds a facet counting the values of the given numeric field in each range.
+func WithSearchNumericRangeFacet(name, field string, ranges ...NumericRange) SearchOption {
+	return func(r *bleve.SearchRequest) {
+		facet := bleve.NewFacetRequest(field, len(ranges))
+		for _, nr := range ranges {
+			facet.AddNumericRange(nr.Name, nr.Min, nr.Max)
+		}
+
+		r.AddFacet(name, facet)
+	}
+}
+
+// WithSearchDateRangeFacet adds a facet counting the values of the given date field in each range.
+func WithSearchDateRangeFacet(name, field string, ranges ...DateRange) SearchOption {
+	return func(r *bleve.SearchRequest) {
+		facet := bleve.NewFacetRequest(field, len(ranges))
+		for _, dr := range ranges {
+			facet.AddDateTimeRange(dr.Name, dr.Start, dr.End)
+		}
+
+		r.AddFacet(name, facet)
+	}
+}
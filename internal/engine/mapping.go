@@ -0,0 +1,147 @@
+package engine
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2/mapping"
+)
+
+// ErrUnknownMappingPath is returned when a mapped path is not a field of the indexed document.
+var ErrUnknownMappingPath = errors.New("mapped path not found in the document")
+
+var (
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// ValidateMapping checks that every path of the default document mapping is a field of the given document,
+// as serialised by its JSON tags. A mapping on a misspelled path is silently ignored by the index,
+// the field being indexed with the default dynamic mapping instead.
+// So is a property named after a dotted path: nested fields need a sub document mapping per parent.
+func ValidateMapping(m *mapping.IndexMappingImpl, doc any) error {
+	if m.DefaultMapping == nil {
+		return nil
+	}
+
+	paths := make(map[string]bool)
+	jsonPaths(reflect.TypeOf(doc), "", paths)
+
+	missing := make([]string, 0)
+	walkMapping(m.DefaultMapping, "", func(path, name string, _ *mapping.DocumentMapping) {
+		if strings.Contains(name, ".") || !paths[path] && !underDynamicPath(path, paths) {
+			missing = append(missing, path)
+		}
+	})
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("%w: %s", ErrUnknownMappingPath, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// walkMapping calls fn with the path, the name and the mapping of every property of the document mapping,
+// nested ones included.
+func walkMapping(m *mapping.DocumentMapping, prefix string, fn func(path, name string, property *mapping.DocumentMapping)) {
+	for name, property := range m.Properties {
+		path := prefix + name
+		fn(path, name, property)
+		walkMapping(property, path+".", fn)
+	}
+}
+
+// fieldAnalyzers returns the analyzer of the fields of the default document mapping, by field name.
+// The index resolves the analyzer of a queried field by its path, so the one of a field renamed
+// by its mapping, such as owner.words, must be set on its queries or the default analyzer applies.
+func fieldAnalyzers(m mapping.IndexMapping) map[string]string {
+	analyzers := make(map[string]string)
+	impl, ok := m.(*mapping.IndexMappingImpl)
+	if !ok || impl.DefaultMapping == nil {
+		return analyzers
+	}
+
+	walkMapping(impl.DefaultMapping, "", func(path, name string, property *mapping.DocumentMapping) {
+		for _, field := range property.Fields {
+			if field.Analyzer == "" {
+				continue
+			}
+
+			// a renamed field is named after its parent path
+			fieldName := path
+			if field.Name != "" {
+				fieldName = strings.TrimSuffix(path, name) + field.Name
+			}
+
+			analyzers[fieldName] = field.Analyzer
+		}
+	})
+
+	return analyzers
+}
+
+// underDynamicPath reports whether path is below a map field, whose keys are only known at indexing time.
+func underDynamicPath(path string, paths map[string]bool) bool {
+	for i := strings.LastIndexByte(path, '.'); i > 0; i = strings.LastIndexByte(path[:i], '.') {
+		if paths[path[:i]+".*"] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// jsonPaths adds to paths the dotted JSON path of every field of t, as flattened by the index:
+// slice elements share the path of the slice, map keys are recorded as "<path>.*".
+func jsonPaths(t reflect.Type, prefix string, paths map[string]bool) {
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		t = t.Elem()
+	}
+
+	if prefix != "" {
+		paths[strings.TrimSuffix(prefix, ".")] = true
+	}
+
+	// values serialised as a whole, such as time.Time
+	if t.Implements(jsonMarshalerType) || t.Implements(textMarshalerType) ||
+		reflect.PointerTo(t).Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType) {
+		return
+	}
+
+	if t.Kind() == reflect.Map {
+		paths[prefix+"*"] = true
+		return
+	}
+
+	if t.Kind() == reflect.Struct {
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() && !field.Anonymous {
+				continue
+			}
+
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" {
+				continue
+			}
+
+			// embedded structs without a name are flattened into their parent
+			if name == "" && field.Anonymous {
+				jsonPaths(field.Type, prefix, paths)
+				continue
+			}
+
+			if name == "" {
+				name = field.Name
+			}
+
+			jsonPaths(field.Type, prefix+name+".", paths)
+		}
+	}
+}
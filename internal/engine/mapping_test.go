@@ -0,0 +1,58 @@
+package engine
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/SkYNewZ/gh-stars-search-engine/internal/github"
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/mapping"
+)
+
+// newTestMapping returns an index mapping with a keyword field at each of the given nested paths,
+// the parents of a nested field getting their own sub document mapping.
+func newTestMapping(paths ...[]string) *mapping.IndexMappingImpl {
+	root := bleve.NewDocumentMapping()
+	for _, path := range paths {
+		dm := root
+		for _, parent := range path[:len(path)-1] {
+			sub, ok := dm.Properties[parent]
+			if !ok {
+				sub = bleve.NewDocumentMapping()
+				dm.AddSubDocumentMapping(parent, sub)
+			}
+
+			dm = sub
+		}
+
+		dm.AddFieldMappingsAt(path[len(path)-1], bleve.NewKeywordFieldMapping())
+	}
+
+	m := bleve.NewIndexMapping()
+	m.DefaultMapping = root
+	return m
+}
+
+func TestValidateMapping(t *testing.T) {
+	tests := []struct {
+		name    string
+		paths   [][]string
+		wantErr error
+	}{
+		{
+			name:  "valid",
+			paths: [][]string{{"id"}, {"owner", "login"}, {"primary_language", "name"}, {"starred_at_by", "octocat"}},
+		},
+		{name: "misspelled path", paths: [][]string{{"id"}, {"stargazers_count"}}, wantErr: ErrUnknownMappingPath},
+		{name: "wrong sub path", paths: [][]string{{"owner", "name"}}, wantErr: ErrUnknownMappingPath},
+		{name: "dotted property", paths: [][]string{{"owner.login"}}, wantErr: ErrUnknownMappingPath},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateMapping(newTestMapping(tt.paths...), github.Repository{}); !errors.Is(err, tt.wantErr) {
+				t.Errorf("ValidateMapping() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
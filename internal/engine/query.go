@@ -0,0 +1,258 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// SearchMode is the way the search text is turned into a query.
+type SearchMode string
+
+const (
+	// SearchModeQueryString parses the text with the query string syntax, see https://blevesearch.com/docs/Query-String-Query/.
+	SearchModeQueryString SearchMode = "querystring"
+
+	// SearchModeFuzzy matches each term of the text within the edit distance, or as a prefix, in the boosted fields.
+	SearchModeFuzzy SearchMode = "fuzzy"
+
+	// SearchModePrefix matches each term of the text as is, or as a prefix, in the boosted fields.
+	SearchModePrefix SearchMode = "prefix"
+
+	// SearchModeHybrid matches any term of the text, or documents whose vector is close to the text vector.
+	// Results are ranked by a fusion of the keyword and the vector scores.
+	SearchModeHybrid SearchMode = "hybrid"
+)
+
+// DefaultVectorWeight is the default weight of the vector similarity in the hybrid score, from 0 to 1.
+const DefaultVectorWeight float64 = 0.5
+
+// MaxFuzziness is the maximum edit distance supported by the fuzzy mode.
+const MaxFuzziness int = 2
+
+var (
+	// ErrInvalidSearchMode is returned when the search mode is unknown.
+	ErrInvalidSearchMode = errors.New("invalid search mode")
+
+	// ErrInvalidFuzziness is returned when the edit distance is out of range.
+	ErrInvalidFuzziness = errors.New("invalid fuzziness")
+
+	// ErrInvalidVectorWeight is returned when the vector weight is out of range.
+	ErrInvalidVectorWeight = errors.New("invalid vector weight")
+
+	// ErrEmptyQuery is returned when the search text has no term.
+	ErrEmptyQuery = errors.New("empty query")
+)
+
+type queryConfig struct {
+	mode         SearchMode
+	fuzziness    int
+	boosts       map[string]float64
+	vectorWeight float64
+}
+
+// TextQuery is the query matching a search text, see NewQuery.
+type TextQuery struct {
+	query.Query
+
+	Text string // free text of the search, matched by the relevance field boosts
+
+	// terms of the fuzzy and prefix modes, matched in the field boosts of the engine relevance, see fieldQuery
+	terms []string
+	conf  *queryConfig
+}
+
+// fieldQuery returns the query matching the terms in the given boosted fields, analyzed as the fields are,
+// unless they are set by WithQueryFieldBoosts or the query does not depend on them.
+func (q *TextQuery) fieldQuery(boosts map[string]float64, analyzers map[string]string) query.Query {
+	if len(q.terms) == 0 {
+		return q.Query
+	}
+
+	if q.conf.boosts != nil {
+		boosts = q.conf.boosts
+	}
+
+	conjuncts := make([]query.Query, 0, len(q.terms))
+	for _, term := range q.terms {
+		conjuncts = append(conjuncts, q.conf.termQuery(term, boosts, analyzers))
+	}
+
+	return bleve.NewConjunctionQuery(conjuncts...)
+}
+
+// HybridQuery is the query of the hybrid mode.
+// The keyword query is executed over the documents matching it or having a vector close to the text one.
+type HybridQuery struct {
+	query.Query // keyword query
+
+	Text         string  // text the query vector is computed from
+	VectorWeight float64 // weight of the vector similarity in the score, from 0 to 1
+}
+
+// QueryOption configures the query built by NewQuery.
+type QueryOption func(*queryConfig)
+
+// WithQueryMode sets the search mode, SearchModeQueryString by default.
+func WithQueryMode(mode SearchMode) QueryOption {
+	return func(c *queryConfig) {
+		c.mode = mode
+	}
+}
+
+// WithQueryFuzziness sets the edit distance of the fuzzy mode, from 0 to MaxFuzziness.
+func WithQueryFuzziness(fuzziness int) QueryOption {
+	return func(c *queryConfig) {
+		c.fuzziness = fuzziness
+	}
+}
+
+// WithQueryFieldBoosts sets the fields searched by the fuzzy and prefix modes, with their boost.
+// Defaults to the field boosts of the engine relevance, see WithRelevance.
+func WithQueryFieldBoosts(boosts map[string]float64) QueryOption {
+	return func(c *queryConfig) {
+		c.boosts = boosts
+	}
+}
+
+// WithQueryVectorWeight sets the weight of the vector similarity in the score of the hybrid mode, from 0 to 1.
+func WithQueryVectorWeight(weight float64) QueryOption {
+	return func(c *queryConfig) {
+		c.vectorWeight = weight
+	}
+}
+
+// NewQuery returns the query matching the given search text according to the search mode.
+// In the fuzzy and prefix modes, every term of the text must match at least one of the boosted fields.
+func NewQuery(text string, opts ...QueryOption) (query.Query, error) {
+	conf := &queryConfig{
+		mode:      SearchModeQueryString,
+		fuzziness: MaxFuzziness,
+
+		vectorWeight: DefaultVectorWeight,
+	}
+
+	for _, opt := range opts {
+		opt(conf)
+	}
+
+	if conf.fuzziness < 0 || conf.fuzziness > MaxFuzziness {
+		return nil, fmt.Errorf("%w: %d, expected between 0 and %d", ErrInvalidFuzziness, conf.fuzziness, MaxFuzziness)
+	}
+
+	if conf.vectorWeight < 0 || conf.vectorWeight > 1 {
+		return nil, fmt.Errorf("%w: %g, expected between 0 and 1", ErrInvalidVectorWeight, conf.vectorWeight)
+	}
+
+	switch conf.mode {
+	case SearchModeQueryString:
+		// the fielded, excluded and pattern terms are not free text, an invalid query fails at search time
+		q := bleve.NewQueryStringQuery(text)
+		parsed, err := q.Parse()
+		if err != nil {
+			return &TextQuery{Query: q}, nil
+		}
+
+		return &TextQuery{Query: q, Text: strings.Join(freeText(parsed), " ")}, nil
+	case SearchModeHybrid:
+		if strings.TrimSpace(text) == "" {
+			return nil, ErrEmptyQuery
+		}
+
+		keyword := bleve.NewMatchQuery(text) // any term, ranked by BM25
+		return &HybridQuery{Query: keyword, Text: text, VectorWeight: conf.vectorWeight}, nil
+	case SearchModeFuzzy, SearchModePrefix:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidSearchMode, conf.mode)
+	}
+
+	terms := strings.Fields(text)
+	if len(terms) == 0 {
+		return nil, ErrEmptyQuery
+	}
+
+	// the engine matches the terms in the fields of its relevance, the default ones until then
+	q := &TextQuery{Text: text, terms: terms, conf: conf}
+	q.Query = q.fieldQuery(DefaultRelevance.FieldBoosts, nil)
+	return q, nil
+}
+
+// termQuery returns the disjunction of the exact, prefix and, in fuzzy mode, fuzzy matches of term in the boosted fields.
+// Exact matches weigh twice as much as the approximate ones, the fields without boost are not searched.
+func (c *queryConfig) termQuery(term string, boosts map[string]float64, analyzers map[string]string) query.Query {
+	disjuncts := make([]query.Query, 0, len(boosts)*3)
+	for field, boost := range boosts {
+		if boost <= 0 {
+			continue
+		}
+
+		exact := newFieldMatchQuery(term, field, analyzers)
+		exact.SetBoost(boost * 2)
+
+		prefix := bleve.NewPrefixQuery(strings.ToLower(term))
+		prefix.SetField(field)
+		prefix.SetBoost(boost)
+		disjuncts = append(disjuncts, exact, prefix)
+
+		if c.mode == SearchModeFuzzy && c.fuzziness > 0 {
+			fuzzy := newFieldMatchQuery(term, field, analyzers)
+			fuzzy.SetFuzziness(c.fuzziness)
+			fuzzy.SetBoost(boost)
+			disjuncts = append(disjuncts, fuzzy)
+		}
+	}
+
+	return bleve.NewDisjunctionQuery(disjuncts...)
+}
+
+// newFieldMatchQuery returns the query matching text in the given field, analyzed with the analyzer of the field, if known.
+func newFieldMatchQuery(text, field string, analyzers map[string]string) *query.MatchQuery {
+	match := bleve.NewMatchQuery(text)
+	match.SetField(field)
+	match.Analyzer = analyzers[field]
+	return match
+}
+
+// freeText returns the terms and phrases of the parsed query string matched in any field, the excluded ones aside.
+func freeText(q query.Query) []string {
+	switch q := q.(type) {
+	case *query.BooleanQuery:
+		terms := make([]string, 0)
+		if q.Must != nil {
+			terms = append(terms, freeText(q.Must)...)
+		}
+
+		if q.Should != nil {
+			terms = append(terms, freeText(q.Should)...)
+		}
+
+		return terms
+	case *query.ConjunctionQuery:
+		return freeTextOf(q.Conjuncts)
+	case *query.DisjunctionQuery:
+		return freeTextOf(q.Disjuncts)
+	case *query.MatchQuery:
+		if q.FieldVal == "" {
+			return []string{q.Match}
+		}
+	case *query.MatchPhraseQuery:
+		if q.FieldVal == "" {
+			return []string{q.MatchPhrase}
+		}
+	}
+
+	return nil
+}
+
+// freeTextOf returns the free text of the given queries, see freeText.
+func freeTextOf(queries []query.Query) []string {
+	terms := make([]string, 0)
+	for _, q := range queries {
+		terms = append(terms, freeText(q)...)
+	}
+
+	return terms
+}
@@ -0,0 +1,239 @@
+package engine
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/mapping"
+)
+
+const (
+	// mappingVersionKey is the metadata key holding the version of the mapping the index has been built with.
+	mappingVersionKey string = "mapping_version"
+
+	// currentFileName is the file of the storage directory holding the name of the serving index directory.
+	currentFileName string = "CURRENT"
+
+	// indexDirPrefix prefixes the name of the index directories, followed by their mapping version.
+	indexDirPrefix string = "index-"
+
+	// legacyMetaFileName is the metadata file of an index created directly in the storage directory.
+	legacyMetaFileName string = "index_meta.json"
+)
+
+// legacyFiles are the files of an index created directly in the storage directory.
+var legacyFiles = []string{legacyMetaFileName, "store"}
+
+// ErrNotRebuilding is returned when completing a rebuild while the mapping is up-to-date.
+var ErrNotRebuilding = errors.New("index is not being rebuilt")
+
+// MappingVersion returns the version of the given mapping, a digest of its definition.
+func MappingVersion(m mapping.IndexMapping) (string, error) {
+	definition, err := json.Marshal(m)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode mapping: %w", err)
+	}
+
+	sum := sha256.Sum256(definition)
+	return hex.EncodeToString(sum[:6]), nil
+}
+
+// openIndexes opens the serving index of the storage directory, creating it if none.
+// When it has been built with another mapping version, the index to rebuild with the current mapping
+// is opened too, resuming a previous rebuild if any. Returns a nil next index otherwise.
+func openIndexes(root string, m mapping.IndexMapping, version string, s *storage) (serving, next bleve.Index, err error) {
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	servingPath, err := currentIndexPath(root)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nextPath := filepath.Join(root, indexDirPrefix+version)
+	if servingPath == "" {
+		// first start, the index is built with the current mapping
+		serving, err := openOrCreateIndex(nextPath, m, version, s)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if err := setCurrentIndexPath(root, nextPath); err != nil {
+			return nil, nil, errors.Join(err, serving.Close())
+		}
+
+		return serving, nil, nil
+	}
+
+	serving, err = s.open(servingPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open index %s: %w", servingPath, err)
+	}
+
+	servingVersion, err := serving.GetInternal([]byte(mappingVersionKey))
+	if err != nil {
+		return nil, nil, errors.Join(fmt.Errorf("failed to get mapping version: %w", err), serving.Close())
+	}
+
+	if string(servingVersion) == version {
+		return serving, nil, nil
+	}
+
+	next, err = openOrCreateIndex(nextPath, m, version, s)
+	if err != nil {
+		return nil, nil, errors.Join(err, serving.Close())
+	}
+
+	return serving, next, nil
+}
+
+// openOrCreateIndex opens the index at the given path, or creates it with the given mapping and version.
+func openOrCreateIndex(path string, m mapping.IndexMapping, version string, s *storage) (bleve.Index, error) {
+	index, err := s.create(path, m)
+	if errors.Is(err, bleve.ErrorIndexPathExists) {
+		if index, err = s.open(path); err != nil {
+			return nil, fmt.Errorf("failed to open index %s: %w", path, err)
+		}
+
+		return index, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create index %s: %w", path, err)
+	}
+
+	if err := index.SetInternal([]byte(mappingVersionKey), []byte(version)); err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to set mapping version: %w", err), index.Close())
+	}
+
+	return index, nil
+}
+
+// currentIndexPath returns the path of the serving index of the storage directory, empty if there is none.
+// An index created directly in the storage directory is served until it is rebuilt.
+func currentIndexPath(root string) (string, error) {
+	name, err := os.ReadFile(filepath.Join(root, currentFileName))
+	if err == nil {
+		return filepath.Join(root, strings.TrimSpace(string(name))), nil
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("failed to read serving index: %w", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(root, legacyMetaFileName)); err == nil {
+		return root, nil
+	}
+
+	return "", nil
+}
+
+// setCurrentIndexPath atomically makes the index at the given path the serving index of the storage directory.
+func setCurrentIndexPath(root, path string) error {
+	tmp := filepath.Join(root, currentFileName+".tmp")
+	if err := os.WriteFile(tmp, []byte(filepath.Base(path)+"\n"), 0o600); err != nil {
+		return fmt.Errorf("failed to write serving index: %w", err)
+	}
+
+	if err := os.Rename(tmp, filepath.Join(root, currentFileName)); err != nil {
+		return fmt.Errorf("failed to write serving index: %w", err)
+	}
+
+	return nil
+}
+
+// removeIndex removes the files of the index at the given path of the storage directory.
+func removeIndex(root, path string) error {
+	if path != root {
+		return os.RemoveAll(path)
+	}
+
+	errs := make([]error, 0, len(legacyFiles))
+	for _, name := range legacyFiles {
+		errs = append(errs, os.RemoveAll(filepath.Join(root, name)))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Rebuilding reports whether the index is being rebuilt because its mapping changed.
+// While rebuilding, the documents are indexed in both indexes and the metadata are read and written
+// in the rebuilt index only, so that the indexer fetches everything again. Searches use the old index.
+func (e *engine) Rebuilding() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.next != nil
+}
+
+// CompleteRebuild makes the rebuilt index the serving one and removes the old index.
+// It must only be called once every document has been indexed again.
+func (e *engine) CompleteRebuild() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.next == nil {
+		return ErrNotRebuilding
+	}
+
+	oldPath, err := currentIndexPath(e.root)
+	if err != nil {
+		return err
+	}
+
+	if err := setCurrentIndexPath(e.root, e.next.Name()); err != nil {
+		return err
+	}
+
+	// in-flight searches hold the alias until they complete
+	old := e.serving
+	e.index.Swap([]bleve.Index{e.next}, []bleve.Index{old})
+	e.serving, e.next = e.next, nil
+
+	e.vectorsMu.Lock()
+	e.vectors = nil // loaded again from the rebuilt index
+	e.vectorsMu.Unlock()
+
+	e.logger.Info("swapped to the rebuilt index " + e.serving.Name())
+	if err := old.Close(); err != nil {
+		return fmt.Errorf("failed to close old index: %w", err)
+	}
+
+	if err := removeIndex(e.root, oldPath); err != nil {
+		return fmt.Errorf("failed to remove old index: %w", err)
+	}
+
+	return nil
+}
+
+// metadataIndex returns the index holding the metadata, the rebuilt one while rebuilding.
+func (e *engine) metadataIndex() bleve.Index {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.next != nil {
+		return e.next
+	}
+
+	return e.index
+}
+
+// writeIndexes returns the indexes the documents are written to, the rebuilt one too while rebuilding.
+func (e *engine) writeIndexes() []bleve.Index {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	if e.next != nil {
+		return []bleve.Index{e.index, e.next}
+	}
+
+	return []bleve.Index{e.index}
+}
@@ -0,0 +1,190 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/v2/mapping"
+)
+
+// changedMapping returns a mapping whose version differs from the default one.
+func changedMapping() mapping.IndexMapping {
+	m := bleve.NewIndexMapping()
+	m.DefaultAnalyzer = keyword.Name
+	return m
+}
+
+// indexDir returns the name of the directory of the index built with the given mapping.
+func indexDir(t *testing.T, m mapping.IndexMapping) string {
+	t.Helper()
+
+	version, err := MappingVersion(m)
+	if err != nil {
+		t.Fatalf("MappingVersion() error = %v", err)
+	}
+
+	return indexDirPrefix + version
+}
+
+// currentIndexDir returns the directory named by the CURRENT file of the storage directory.
+func currentIndexDir(t *testing.T, root string) string {
+	t.Helper()
+
+	name, err := os.ReadFile(filepath.Join(root, currentFileName))
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", currentFileName, err)
+	}
+
+	return strings.TrimSpace(string(name))
+}
+
+// engineIDs returns the sorted IDs of the documents searched by the engine.
+func engineIDs(t *testing.T, e *engine) []string {
+	t.Helper()
+
+	ids, err := e.IDs(context.Background())
+	if err != nil {
+		t.Fatalf("IDs() error = %v", err)
+	}
+
+	slices.Sort(ids)
+	return ids
+}
+
+// docCount returns the number of documents of the index.
+func docCount(t *testing.T, index bleve.Index) uint64 {
+	t.Helper()
+
+	count, err := index.DocCount()
+	if err != nil {
+		t.Fatalf("DocCount() error = %v", err)
+	}
+
+	return count
+}
+
+func TestRebuild(t *testing.T) {
+	root := t.TempDir()
+	oldMapping, newMapping := bleve.NewIndexMapping(), changedMapping()
+
+	e := openTestEngine(t, root, oldMapping)
+	if err := e.BatchIndex([]Indexable{&testDocument{ID: "1", Description: "indexed before the change"}}, 10); err != nil {
+		t.Fatalf("BatchIndex() error = %v", err)
+	}
+
+	closeTestEngine(e)
+
+	// the mapping changed, the index is rebuilt in a new directory while the old one keeps serving
+	e = openTestEngine(t, root, newMapping)
+	if !e.Rebuilding() {
+		t.Fatal("Rebuilding() = false, want true after a mapping change")
+	}
+
+	if got, want := filepath.Base(e.next.Name()), indexDir(t, newMapping); got != want {
+		t.Errorf("rebuilt index directory = %s, want %s", got, want)
+	}
+
+	if err := e.BatchIndex([]Indexable{&testDocument{ID: "2", Description: "indexed during the rebuild"}}, 10); err != nil {
+		t.Fatalf("BatchIndex() error = %v", err)
+	}
+
+	if serving, next := docCount(t, e.serving), docCount(t, e.next); serving != 2 || next != 1 {
+		t.Errorf("documents = %d serving and %d rebuilt, want 2 and 1, written to both indexes", serving, next)
+	}
+
+	if ids := engineIDs(t, e); !slices.Equal(ids, []string{"1", "2"}) {
+		t.Errorf("IDs() = %v, want [1 2] from the serving index", ids)
+	}
+
+	if err := e.CompleteRebuild(); err != nil {
+		t.Fatalf("CompleteRebuild() error = %v", err)
+	}
+
+	// the alias and the CURRENT file point to the rebuilt index, the old one is removed
+	if e.Rebuilding() {
+		t.Error("Rebuilding() = true, want false once completed")
+	}
+
+	if ids := engineIDs(t, e); !slices.Equal(ids, []string{"2"}) {
+		t.Errorf("IDs() = %v, want [2] from the rebuilt index", ids)
+	}
+
+	if got, want := currentIndexDir(t, root), indexDir(t, newMapping); got != want {
+		t.Errorf("%s = %s, want %s", currentFileName, got, want)
+	}
+
+	if _, err := os.Stat(filepath.Join(root, indexDir(t, oldMapping))); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("old index stat error = %v, want it removed", err)
+	}
+
+	if err := e.CompleteRebuild(); !errors.Is(err, ErrNotRebuilding) {
+		t.Errorf("CompleteRebuild() error = %v, want %v", err, ErrNotRebuilding)
+	}
+
+	closeTestEngine(e)
+	if e = openTestEngine(t, root, newMapping); e.Rebuilding() {
+		t.Error("Rebuilding() = true after reopening the rebuilt index, want false")
+	}
+}
+
+func TestRebuildLegacyIndex(t *testing.T) {
+	// an index created directly in the storage directory, without mapping version
+	root := filepath.Join(t.TempDir(), "ghs.bleve")
+	m := bleve.NewIndexMapping()
+	legacy, err := bleve.New(root, m)
+	if err != nil {
+		t.Fatalf("bleve.New() error = %v", err)
+	}
+
+	if err := legacy.Index("1", &testDocument{ID: "1", Description: "legacy"}); err != nil {
+		t.Fatalf("Index() error = %v", err)
+	}
+
+	if err := legacy.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	e := openTestEngine(t, root, m)
+	if !e.Rebuilding() {
+		t.Fatal("Rebuilding() = false, want the legacy index to be rebuilt")
+	}
+
+	if ids := engineIDs(t, e); !slices.Equal(ids, []string{"1"}) {
+		t.Errorf("IDs() = %v, want [1], the legacy index serves until rebuilt", ids)
+	}
+
+	if err := e.BatchIndex([]Indexable{&testDocument{ID: "1", Description: "legacy"}}, 10); err != nil {
+		t.Fatalf("BatchIndex() error = %v", err)
+	}
+
+	if err := e.CompleteRebuild(); err != nil {
+		t.Fatalf("CompleteRebuild() error = %v", err)
+	}
+
+	if got, want := currentIndexDir(t, root), indexDir(t, m); got != want {
+		t.Errorf("%s = %s, want %s", currentFileName, got, want)
+	}
+
+	for _, name := range legacyFiles {
+		if _, err := os.Stat(filepath.Join(root, name)); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("legacy %s stat error = %v, want it removed", name, err)
+		}
+	}
+
+	closeTestEngine(e)
+	e = openTestEngine(t, root, m)
+	if e.Rebuilding() {
+		t.Error("Rebuilding() = true after reopening the migrated index, want false")
+	}
+
+	if ids := engineIDs(t, e); !slices.Equal(ids, []string{"1"}) {
+		t.Errorf("IDs() = %v, want [1] from the migrated index", ids)
+	}
+}
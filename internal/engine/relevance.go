@@ -0,0 +1,90 @@
+package engine
+
+import (
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// Relevance tunes the score of the documents matching a search text.
+// The boosts only reorder the results, they never restrict them.
+type Relevance struct {
+	// FieldBoosts boosts the documents matching the search text in the given fields.
+	// They are also the fields searched by the fuzzy and prefix modes, unless set by WithQueryFieldBoosts.
+	FieldBoosts map[string]float64
+
+	// Popularity boosts the documents by tiers of stargazers (100, 1000 and 10000 stars), 0 disables it.
+	Popularity float64
+
+	// Recency boosts the documents starred during the last year, and even more the last month, 0 disables it.
+	Recency float64
+}
+
+// DefaultRelevance ranks the name matches first, then the owner, topics, description and readme ones.
+var DefaultRelevance = Relevance{
+	FieldBoosts: map[string]float64{
+		"name_with_owner": 3,
+		"name_stemmed":    2, // stemmed name words, below the exact parts
+		"owner.words":     2,
+		"topics":          2,
+		"description":     1.5,
+		"readme_headings": 1.25,
+		"readme":          0.5,
+	},
+}
+
+// popularityTiers are the stargazer counts from which the popularity boost applies, once per reached tier.
+var popularityTiers = []float64{100, 1000, 10000}
+
+// recencyPeriods are the periods since the star during which the recency boost applies, once per period.
+var recencyPeriods = []time.Duration{30 * 24 * time.Hour, 365 * 24 * time.Hour}
+
+// WithRelevance sets how the documents matching a search text are ranked.
+// Defaults to DefaultRelevance.
+func WithRelevance(relevance Relevance) Option {
+	return func(e *engine) {
+		e.relevance = relevance
+	}
+}
+
+// boost returns the given query with the relevance boosts as optional clauses.
+// The field boosts match the given free text, none apply without it, analyzed as the fields are.
+func (r *Relevance) boost(q query.Query, text string, now time.Time, analyzers map[string]string) query.Query {
+	should := make([]query.Query, 0)
+	for field, boost := range r.FieldBoosts {
+		if boost <= 0 || strings.TrimSpace(text) == "" {
+			continue
+		}
+
+		match := newFieldMatchQuery(text, field, analyzers)
+		match.SetBoost(boost)
+		should = append(should, match)
+	}
+
+	if r.Popularity > 0 {
+		for _, tier := range popularityTiers {
+			tier := tier // the range query keeps the pointer
+			popular := bleve.NewNumericRangeQuery(&tier, nil)
+			popular.SetField("stargazer_count")
+			popular.SetBoost(r.Popularity)
+			should = append(should, popular)
+		}
+	}
+
+	if r.Recency > 0 {
+		for _, period := range recencyPeriods {
+			recent := bleve.NewDateRangeQuery(now.Add(-period), time.Time{})
+			recent.SetField("starred_at")
+			recent.SetBoost(r.Recency)
+			should = append(should, recent)
+		}
+	}
+
+	if len(should) == 0 {
+		return q
+	}
+
+	return query.NewBooleanQuery([]query.Query{q}, should, nil)
+}
@@ -0,0 +1,83 @@
+package engine
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testRepository is a starred repository indexed by the relevance tests.
+type testRepository struct {
+	ID             string    `json:"id"`
+	Description    string    `json:"description"`
+	StargazerCount int       `json:"stargazer_count"`
+	StarredAt      time.Time `json:"starred_at"`
+}
+
+func (r *testRepository) GetID() string {
+	return r.ID
+}
+
+func TestRelevanceBoost(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		relevance Relevance
+		repos     []Indexable
+		want      []string
+	}{
+		{
+			name:      "popularity tiers",
+			relevance: Relevance{Popularity: 1},
+			repos: []Indexable{
+				&testRepository{ID: "10", Description: "tool", StargazerCount: 10},
+				&testRepository{ID: "500", Description: "tool", StargazerCount: 500},
+				&testRepository{ID: "50000", Description: "tool", StargazerCount: 50000},
+				&testRepository{ID: "5000", Description: "tool", StargazerCount: 5000},
+			},
+			want: []string{"50000", "5000", "500", "10"},
+		},
+		{
+			name:      "recency periods",
+			relevance: Relevance{Recency: 1},
+			repos: []Indexable{
+				&testRepository{ID: "old", Description: "tool", StarredAt: now.AddDate(-2, 0, 0)},
+				&testRepository{ID: "week", Description: "tool", StarredAt: now.AddDate(0, 0, -7)},
+				&testRepository{ID: "months", Description: "tool", StarredAt: now.AddDate(0, -6, 0)},
+			},
+			want: []string{"week", "months", "old"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newTestEngine(t, "", WithMemoryStorage(), WithRelevance(tt.relevance))
+			if err := e.BatchIndex(tt.repos, 10); err != nil {
+				t.Fatalf("BatchIndex() error = %v", err)
+			}
+
+			q, err := NewQuery("tool")
+			if err != nil {
+				t.Fatalf("NewQuery() error = %v", err)
+			}
+
+			results, err := e.Search(context.Background(), q)
+			if err != nil {
+				t.Fatalf("Search() error = %v", err)
+			}
+
+			ids := make([]string, 0, len(results.Hits))
+			for i, hit := range results.Hits {
+				ids = append(ids, hit.ID)
+				if i > 0 && hit.Score >= results.Hits[i-1].Score {
+					t.Errorf("Search() score of %s = %g, want less than %g", hit.ID, hit.Score, results.Hits[i-1].Score)
+				}
+			}
+
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("Search() = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
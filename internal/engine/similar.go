@@ -0,0 +1,172 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+	index "github.com/blevesearch/bleve_index_api"
+)
+
+const (
+	// similarMaxTerms is the number of most significant terms the similar documents are searched with.
+	similarMaxTerms int = 25
+
+	// similarMinTermLength skips the terms too short to be significant.
+	similarMinTermLength int = 3
+)
+
+// ErrNotFound is returned when the requested document is not indexed.
+var ErrNotFound = errors.New("document not found")
+
+// similarFields are the stored fields the significant terms of a document are taken from.
+var similarFields = []string{"description", "readme", "topics"}
+
+// significantTerm is a term of the source document weighted by its TF-IDF.
+type significantTerm struct {
+	field  string
+	term   string
+	weight float64
+}
+
+// Similar returns the documents similar to the document having the given ID, the most similar first.
+// The query is built from the most significant terms of the document description, readme and topics,
+// weighted by their TF-IDF over the index. The document itself is excluded from the results.
+func (e *engine) Similar(ctx context.Context, id string, opts ...SearchOption) (*bleve.SearchResult, error) {
+	e.mu.RLock() // the dictionaries and documents are read from the serving index
+	defer e.mu.RUnlock()
+
+	doc, err := e.index.Document(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document %s: %w", id, err)
+	}
+
+	if doc == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+
+	terms, err := e.significantTerms(doc)
+	if err != nil {
+		return nil, err
+	}
+
+	disjuncts := make([]query.Query, 0, len(terms))
+	for _, t := range terms {
+		q := bleve.NewTermQuery(t.term)
+		q.SetField(t.field)
+		q.SetBoost(t.weight)
+		disjuncts = append(disjuncts, q)
+	}
+
+	// a document without significant term matches nothing
+	must := []query.Query{bleve.NewMatchNoneQuery()}
+	if len(disjuncts) > 0 {
+		must = []query.Query{bleve.NewDisjunctionQuery(disjuncts...)}
+	}
+
+	q := query.NewBooleanQuery(must, nil, []query.Query{bleve.NewDocIDQuery([]string{id})})
+	results, err := e.Search(ctx, q, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// documents sharing no significant term are not similar
+	hits := results.Hits[:0]
+	for _, hit := range results.Hits {
+		if hit.Score > 0 {
+			hits = append(hits, hit)
+		}
+	}
+
+	results.Total -= uint64(len(results.Hits) - len(hits))
+	results.Hits = hits
+
+	return results, nil
+}
+
+// significantTerms returns the terms of the similar fields of the document having the highest TF-IDF.
+// Terms not shared with any other document, or shared with all of them, are skipped.
+func (e *engine) significantTerms(doc index.Document) ([]*significantTerm, error) {
+	count, err := e.index.DocCount()
+	if err != nil {
+		return nil, fmt.Errorf("failed to count documents: %w", err)
+	}
+
+	// term frequencies by field, fields are analyzed again since only their stored value is available
+	frequencies := make(map[string]map[string]int)
+	doc.VisitFields(func(field index.Field) {
+		text, ok := field.(index.TextField)
+		if !ok || !slices.Contains(similarFields, field.Name()) {
+			return
+		}
+
+		analyzer := e.index.Mapping().AnalyzerNamed(e.index.Mapping().AnalyzerNameForPath(field.Name()))
+		if analyzer == nil {
+			return
+		}
+
+		if frequencies[field.Name()] == nil {
+			frequencies[field.Name()] = make(map[string]int)
+		}
+
+		for _, token := range analyzer.Analyze([]byte(text.Text())) {
+			if len(token.Term) >= similarMinTermLength {
+				frequencies[field.Name()][string(token.Term)]++
+			}
+		}
+	})
+
+	terms := make([]*significantTerm, 0)
+	for field, tfs := range frequencies {
+		for term, tf := range tfs {
+			df, err := e.docFrequency(field, term)
+			if err != nil {
+				return nil, err
+			}
+
+			if df < 2 {
+				continue // only the source document has this term
+			}
+
+			// a term present in every document does not discriminate anything
+			weight := float64(tf) * math.Log(float64(count)/float64(df))
+			if weight <= 0 {
+				continue
+			}
+
+			terms = append(terms, &significantTerm{field: field, term: term, weight: weight})
+		}
+	}
+
+	sort.Slice(terms, func(i, j int) bool {
+		return terms[i].weight > terms[j].weight
+	})
+
+	terms = terms[:min(similarMaxTerms, len(terms))]
+	return terms, nil
+}
+
+// docFrequency returns the number of documents having the given term in the given field.
+func (e *engine) docFrequency(field, term string) (uint64, error) {
+	dict, err := e.index.FieldDictRange(field, []byte(term), []byte(term))
+	if err != nil {
+		return 0, fmt.Errorf("failed to read terms of %s: %w", field, err)
+	}
+	defer func() { _ = dict.Close() }()
+
+	entry, err := dict.Next()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read terms of %s: %w", field, err)
+	}
+
+	if entry == nil || entry.Term != term {
+		return 0, nil
+	}
+
+	return entry.Count, nil
+}
@@ -0,0 +1,84 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+)
+
+// Stats are the statistics of the index.
+type Stats struct {
+	Documents  uint64  `json:"documents"`
+	DiskSize   int64   `json:"disk_size"` // bytes used by the storage directory, 0 in memory
+	Backend    Backend `json:"backend"`
+	InMemory   bool    `json:"in_memory"`
+	Rebuilding bool    `json:"rebuilding"` // whether the index is being rebuilt, see Rebuilding
+}
+
+// DocCount returns the number of documents of the serving index, without walking the storage directory.
+func (e *engine) DocCount() (uint64, error) {
+	count, err := e.index.DocCount()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count documents: %w", err)
+	}
+
+	return count, nil
+}
+
+// Stats returns the statistics of the serving index.
+// While rebuilding, the disk size includes the rebuilt index.
+func (e *engine) Stats(ctx context.Context) (*Stats, error) {
+	count, err := e.DocCount()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{
+		Documents:  count,
+		Backend:    e.storage.backend,
+		InMemory:   e.storage.memory,
+		Rebuilding: e.Rebuilding(),
+	}
+
+	if e.storage.memory {
+		return stats, nil
+	}
+
+	err = filepath.WalkDir(e.root, func(_ string, entry fs.DirEntry, err error) error {
+		// the segments are removed by the merges and the old indexes by the rebuilds while walking
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if entry.IsDir() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		stats.DiskSize += info.Size()
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute disk size: %w", err)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,142 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/index/scorch"
+	"github.com/blevesearch/bleve/v2/index/upsidedown"
+	"github.com/blevesearch/bleve/v2/index/upsidedown/store/boltdb"
+	"github.com/blevesearch/bleve/v2/mapping"
+)
+
+// Backend is the implementation storing the index.
+type Backend string
+
+const (
+	// BackendScorch stores the index in immutable segments merged in the background, the default.
+	BackendScorch Backend = "scorch"
+
+	// BackendBoltDB stores the index in a BoltDB key value store, with the upsidedown layout.
+	// It is slower and larger than scorch and does not support snapshots.
+	BackendBoltDB Backend = "boltdb"
+)
+
+// ErrInvalidBackend is returned when the storage backend is unknown.
+var ErrInvalidBackend = errors.New("invalid storage backend")
+
+// storage configures how the indexes are stored.
+type storage struct {
+	backend Backend
+	memory  bool           // nothing is persisted, the storage directory is ignored
+	config  map[string]any // runtime configuration of the index, see WithStorageConfig
+}
+
+// MergePolicy tunes how the scorch segments are merged, see https://pkg.go.dev/github.com/blevesearch/bleve/v2/index/scorch/mergeplan.
+// Zero values keep the defaults.
+type MergePolicy struct {
+	MaxSegmentsPerTier   int     // segments allowed per tier before merging them
+	MaxSegmentSize       int64   // maximum number of documents of a merged segment
+	TierGrowth           float64 // growth factor of the segment size between tiers
+	SegmentsPerMergeTask int     // segments merged at once
+	FloorSegmentSize     int64   // segments smaller than this are treated as this size
+}
+
+// WithBackend sets the implementation storing the index, BackendScorch by default.
+// Changing the backend of an existing storage directory rebuilds the index, see Rebuilding.
+func WithBackend(backend Backend) Option {
+	return func(e *engine) {
+		e.storage.backend = backend
+	}
+}
+
+// WithMemoryStorage keeps the index in memory, the storage directory is ignored.
+// The index is lost when the process exits, use it for tests and ephemeral deployments.
+func WithMemoryStorage() Option {
+	return func(e *engine) {
+		e.storage.memory = true
+	}
+}
+
+// WithMergePolicy tunes how the segments of the scorch backend are merged.
+func WithMergePolicy(policy MergePolicy) Option {
+	options := make(map[string]any)
+	if policy.MaxSegmentsPerTier > 0 {
+		options["MaxSegmentsPerTier"] = policy.MaxSegmentsPerTier
+	}
+
+	if policy.MaxSegmentSize > 0 {
+		options["MaxSegmentSize"] = policy.MaxSegmentSize
+	}
+
+	if policy.TierGrowth > 0 {
+		options["TierGrowth"] = policy.TierGrowth
+	}
+
+	if policy.SegmentsPerMergeTask > 0 {
+		options["SegmentsPerMergeTask"] = policy.SegmentsPerMergeTask
+	}
+
+	if policy.FloorSegmentSize > 0 {
+		options["FloorSegmentSize"] = policy.FloorSegmentSize
+	}
+
+	return WithStorageConfig(map[string]any{"scorchMergePlanOptions": options})
+}
+
+// WithPersistedSnapshots sets the number of previous states of the scorch backend kept on disk, to roll back to.
+func WithPersistedSnapshots(count int) Option {
+	return WithStorageConfig(map[string]any{"numSnapshotsToKeep": count})
+}
+
+// WithStorageConfig sets runtime configuration keys of the index, such as the scorch "unsafe_batch".
+// See the bleve index implementations for the supported keys.
+func WithStorageConfig(config map[string]any) Option {
+	return func(e *engine) {
+		if e.storage.config == nil {
+			e.storage.config = make(map[string]any, len(config))
+		}
+
+		maps.Copy(e.storage.config, config)
+	}
+}
+
+// validate checks the storage configuration.
+func (s *storage) validate() error {
+	if s.backend != BackendScorch && s.backend != BackendBoltDB {
+		return fmt.Errorf("%w: %q", ErrInvalidBackend, s.backend)
+	}
+
+	return nil
+}
+
+// version returns the version of the indexes built with the given mapping version in this storage.
+// The backend is part of it, so that changing the backend rebuilds the index.
+func (s *storage) version(mappingVersion string) string {
+	if s.backend == BackendScorch {
+		return mappingVersion
+	}
+
+	return mappingVersion + "-" + string(s.backend)
+}
+
+// create creates an index at the given path, in memory if the path is empty.
+func (s *storage) create(path string, m mapping.IndexMapping) (bleve.Index, error) {
+	indexType, kvStore := scorch.Name, bleve.Config.DefaultKVStore
+	if s.backend == BackendBoltDB {
+		indexType, kvStore = upsidedown.Name, boltdb.Name
+	}
+
+	if path == "" {
+		kvStore = bleve.Config.DefaultMemKVStore
+	}
+
+	return bleve.NewUsing(path, m, indexType, kvStore, maps.Clone(s.config))
+}
+
+// open opens the index at the given path with the runtime configuration.
+func (s *storage) open(path string) (bleve.Index, error) {
+	return bleve.OpenUsing(path, maps.Clone(s.config))
+}
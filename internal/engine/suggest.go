@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Suggestion is an indexed term completing a prefix.
+type Suggestion struct {
+	Term  string `json:"term"`
+	Field string `json:"field"`
+	Count uint64 `json:"count"` // number of documents having the term
+}
+
+// Suggest returns the indexed terms of the given fields starting with the given prefix.
+// The prefix is lowercased, the fields are expected to be lowercased by their analyzer.
+// Terms are ranked by number of documents, then the shortest first, then by term and field.
+// At most size suggestions are returned.
+func (e *engine) Suggest(ctx context.Context, prefix string, size int, fields ...string) ([]*Suggestion, error) {
+	e.mu.RLock() // the dictionaries and documents are read from the serving index
+	defer e.mu.RUnlock()
+
+	prefix = strings.ToLower(prefix)
+	suggestions := make([]*Suggestion, 0)
+	for _, field := range fields {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("failed to suggest: %w", err)
+		}
+
+		dict, err := e.index.FieldDictPrefix(field, []byte(prefix))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read terms of %s: %w", field, err)
+		}
+
+		entry, err := dict.Next()
+		for ; err == nil && entry != nil; entry, err = dict.Next() {
+			suggestions = append(suggestions, &Suggestion{Term: entry.Term, Field: field, Count: entry.Count})
+		}
+
+		if cerr := dict.Close(); err == nil {
+			err = cerr
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to read terms of %s: %w", field, err)
+		}
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		if suggestions[i].Count != suggestions[j].Count {
+			return suggestions[i].Count > suggestions[j].Count
+		}
+
+		if len(suggestions[i].Term) != len(suggestions[j].Term) {
+			return len(suggestions[i].Term) < len(suggestions[j].Term)
+		}
+
+		if suggestions[i].Term != suggestions[j].Term {
+			return suggestions[i].Term < suggestions[j].Term
+		}
+
+		return suggestions[i].Field < suggestions[j].Field
+	})
+
+	return suggestions[:min(size, len(suggestions))], nil
+}
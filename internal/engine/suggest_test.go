@@ -0,0 +1,36 @@
+package engine
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestSuggest(t *testing.T) {
+	e := newTestEngine(t, "", WithMemoryStorage())
+	docs := []Indexable{
+		&testDocument{ID: "goa", Description: "gol go"},
+		&testDocument{ID: "1", Description: "gob go"},
+		&testDocument{ID: "2", Description: "goa gopher"},
+	}
+
+	if err := e.BatchIndex(docs, 10); err != nil {
+		t.Fatalf("BatchIndex() error = %v", err)
+	}
+
+	// the most frequent first, then the shortest, ties are ordered by term then field whatever the fields order
+	want := []string{"description:go", "description:goa", "id:goa", "description:gob", "description:gol", "description:gopher"}
+	suggestions, err := e.Suggest(context.Background(), "GO", 10, "id", "description")
+	if err != nil {
+		t.Fatalf("Suggest() error = %v", err)
+	}
+
+	got := make([]string, 0, len(suggestions))
+	for _, suggestion := range suggestions {
+		got = append(got, suggestion.Field+":"+suggestion.Term)
+	}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("Suggest() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,226 @@
+package engine
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search"
+	"github.com/blevesearch/bleve/v2/search/query"
+
+	"github.com/SkYNewZ/gh-stars-search-engine/internal/embedding"
+)
+
+// vectorKeyPrefix prefixes the metadata key holding the vector of a document.
+const vectorKeyPrefix string = "vector/"
+
+const (
+	// hybridCandidates is the minimum number of keyword and vector candidates the hybrid results are ranked from.
+	hybridCandidates int = 100
+
+	// hybridMinSimilarity is the cosine similarity below which a document is not a vector candidate.
+	hybridMinSimilarity float64 = 0.1
+
+	// hybridVectorCandidateBoost makes the vector candidates match without weighing on the keyword score.
+	hybridVectorCandidateBoost float64 = 1e-6
+)
+
+// Embeddable is an Indexable having a vector, computed from the text it returns.
+type Embeddable interface {
+	Indexable
+	EmbeddingText() string
+}
+
+// embedBatch returns the vectors of the embeddable documents, keyed by document ID.
+func (e *engine) embedBatch(ctx context.Context, docs []Embeddable) (map[string][]float32, error) {
+	if len(docs) == 0 {
+		return nil, nil
+	}
+
+	texts := make([]string, 0, len(docs))
+	for _, doc := range docs {
+		texts = append(texts, doc.EmbeddingText())
+	}
+
+	vectors, err := e.embedder.Embed(ctx, texts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute vectors: %w", err)
+	}
+
+	byID := make(map[string][]float32, len(docs))
+	for i, doc := range docs {
+		byID[doc.GetID()] = vectors[i]
+	}
+
+	return byID, nil
+}
+
+// loadVectors reads the vectors of every indexed document from the index on first use.
+func (e *engine) loadVectors(ctx context.Context) error {
+	e.vectorsMu.Lock()
+	defer e.vectorsMu.Unlock()
+
+	if e.vectors != nil {
+		return nil
+	}
+
+	ids, err := e.IDs(ctx)
+	if err != nil {
+		return err
+	}
+
+	vectors := make(map[string][]float32, len(ids))
+	for _, id := range ids {
+		value, err := e.index.GetInternal([]byte(vectorKeyPrefix + id))
+		if err != nil {
+			return fmt.Errorf("failed to get vector of %s: %w", id, err)
+		}
+
+		if value != nil {
+			vectors[id] = decodeVector(value)
+		}
+	}
+
+	e.logger.Debug(fmt.Sprintf("loaded %d vectors", len(vectors)))
+	e.vectors = vectors
+	return nil
+}
+
+// setVectors updates the loaded vectors once they are indexed, nil vectors are removed.
+func (e *engine) setVectors(vectors map[string][]float32) {
+	e.vectorsMu.Lock()
+	defer e.vectorsMu.Unlock()
+
+	if e.vectors == nil {
+		return // loaded from the index on first use
+	}
+
+	for id, vector := range vectors {
+		if vector == nil {
+			delete(e.vectors, id)
+			continue
+		}
+
+		e.vectors[id] = vector
+	}
+}
+
+// similarities returns the cosine similarity of the given vector with the vector of every indexed document.
+func (e *engine) similarities(ctx context.Context, vector []float32) (map[string]float64, error) {
+	if err := e.loadVectors(ctx); err != nil {
+		return nil, err
+	}
+
+	e.vectorsMu.RLock()
+	defer e.vectorsMu.RUnlock()
+
+	sims := make(map[string]float64, len(e.vectors))
+	for id, v := range e.vectors {
+		sims[id] = embedding.Cosine(vector, v)
+	}
+
+	return sims, nil
+}
+
+// hybridQuery returns the keyword query of the hybrid query extended to its vector candidates,
+// along with the vector similarity of every document.
+func (e *engine) hybridQuery(ctx context.Context, hq *HybridQuery) (query.Query, map[string]float64, error) {
+	vectors, err := e.embedder.Embed(ctx, hq.Text)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to compute query vector: %w", err)
+	}
+
+	sims, err := e.similarities(ctx, vectors[0])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	candidates := make([]string, 0, len(sims))
+	for id, sim := range sims {
+		if sim >= hybridMinSimilarity {
+			candidates = append(candidates, id)
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		return sims[candidates[i]] > sims[candidates[j]]
+	})
+
+	candidates = candidates[:min(hybridCandidates, len(candidates))]
+	if len(candidates) == 0 {
+		return hq.Query, sims, nil
+	}
+
+	byVector := bleve.NewDocIDQuery(candidates)
+	byVector.SetBoost(hybridVectorCandidateBoost)
+	return bleve.NewDisjunctionQuery(hq.Query, byVector), sims, nil
+}
+
+// hybridSearch executes the search request over the hybrid candidates and ranks them by their fused score:
+// the keyword score normalized by the best one, and the vector similarity, weighted by the vector weight.
+// The sort of the request is ignored.
+func (e *engine) hybridSearch(ctx context.Context, r *bleve.SearchRequest, sims map[string]float64, weight float64) (*bleve.SearchResult, error) {
+	from, size := r.From, r.Size
+	r.From, r.Size = 0, max(from+size, hybridCandidates)
+	r.SortBy([]string{"-_score"})
+
+	results, err := e.index.SearchInContext(ctx, r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search: %w", err)
+	}
+
+	for _, hit := range results.Hits {
+		keyword := 0.0
+		if results.MaxScore > 0 {
+			keyword = hit.Score / results.MaxScore
+		}
+
+		hit.Score = (1-weight)*keyword + weight*math.Max(sims[hit.ID], 0)
+	}
+
+	sort.SliceStable(results.Hits, func(i, j int) bool {
+		return results.Hits[i].Score > results.Hits[j].Score
+	})
+
+	results.MaxScore = 0
+	if len(results.Hits) > 0 {
+		results.MaxScore = results.Hits[0].Score
+	}
+
+	results.Hits = page(results.Hits, from, size)
+	r.From, r.Size = from, size
+	results.Request = r
+	return results, nil
+}
+
+// page returns the hits from the given index.
+func page(hits search.DocumentMatchCollection, from, size int) search.DocumentMatchCollection {
+	if from >= len(hits) {
+		return hits[:0]
+	}
+
+	return hits[from:min(from+size, len(hits))]
+}
+
+// encodeVector encodes the vector as little endian float32.
+func encodeVector(vector []float32) []byte {
+	value := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(value[4*i:], math.Float32bits(v))
+	}
+
+	return value
+}
+
+// decodeVector decodes a vector encoded by encodeVector.
+func decodeVector(value []byte) []float32 {
+	vector := make([]float32, len(value)/4)
+	for i := range vector {
+		vector[i] = math.Float32frombits(binary.LittleEndian.Uint32(value[4*i:]))
+	}
+
+	return vector
+}
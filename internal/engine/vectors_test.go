@@ -0,0 +1,75 @@
+package engine
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+// embeddableDocument is a document indexed with the vector of its description.
+type embeddableDocument struct {
+	testDocument
+}
+
+func (d *embeddableDocument) EmbeddingText() string {
+	return d.Description
+}
+
+// hybridIDs returns the IDs of the documents found by a hybrid search of the given text, from the best ranked one.
+func hybridIDs(t *testing.T, e *engine, text string, weight float64) []string {
+	t.Helper()
+
+	q, err := NewQuery(text, WithQueryMode(SearchModeHybrid), WithQueryVectorWeight(weight))
+	if err != nil {
+		t.Fatalf("NewQuery() error = %v", err)
+	}
+
+	results, err := e.Search(context.Background(), q)
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+
+	ids := make([]string, 0, len(results.Hits))
+	for _, hit := range results.Hits {
+		ids = append(ids, hit.ID)
+	}
+
+	return ids
+}
+
+func TestHybridSearch(t *testing.T) {
+	root := t.TempDir()
+	e := newTestEngine(t, root)
+
+	docs := []Indexable{
+		&embeddableDocument{testDocument{ID: "keyword", Description: "a fast parser of json"}},
+		&embeddableDocument{testDocument{ID: "vector", Description: "parsers of configuration files"}}, // no "parser" term
+		&embeddableDocument{testDocument{ID: "unrelated", Description: "static site generator"}},
+	}
+
+	if err := e.BatchIndex(docs, 10); err != nil {
+		t.Fatalf("BatchIndex() error = %v", err)
+	}
+
+	// the keyword hit ranks first, the vector candidate is merged in
+	want := []string{"keyword", "vector"}
+	if got := hybridIDs(t, e, "parser", 0.5); !slices.Equal(got, want) {
+		t.Errorf("hybrid Search() = %v, want %v", got, want)
+	}
+
+	// the vectors are stored along with the documents and loaded again by a new engine
+	closeTestEngine(e)
+	e = newTestEngine(t, root)
+	if got := hybridIDs(t, e, "parser", 0.5); !slices.Equal(got, want) {
+		t.Errorf("hybrid Search() after reopening = %v, want %v", got, want)
+	}
+
+	vectors, err := e.embedder.Embed(context.Background(), "parsers of configuration files")
+	if err != nil {
+		t.Fatalf("Embed() error = %v", err)
+	}
+
+	if got := e.vectors["vector"]; !slices.Equal(got, vectors[0]) {
+		t.Errorf("loaded vector = %v, want %v", got, vectors[0])
+	}
+}
@@ -6,30 +6,53 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/hasura/go-graphql-client"
 	"golang.org/x/oauth2"
-
-	"github.com/SkYNewZ/gh-stars-search-engine/internal/slogx"
 )
 
 // GraphqlEndpoint is the GitHub GraphQL API endpoint.
 const GraphqlEndpoint string = "https://api.github.com/graphql"
 
+// rateLimitBuffer is the number of rate limit points kept aside before waiting for the reset.
+const rateLimitBuffer int = 10
+
 var (
 	// ErrMissingToken is returned when the GitHub API token is missing.
 	ErrMissingToken = errors.New("missing GitHub API token")
 
 	// ErrMissingEndpoint is returned when the GitHub GraphQL API endpoint is missing.
 	ErrMissingEndpoint = errors.New("missing GitHub GraphQL API endpoint")
+
+	// ErrInvalidEndpoint is returned when the GitHub GraphQL API endpoint is not a valid HTTP(S) URL.
+	ErrInvalidEndpoint = errors.New("invalid GitHub GraphQL API endpoint")
+
+	// ErrRateLimited is returned when the stars listing is interrupted by the GitHub API rate limit.
+	ErrRateLimited = errors.New("GitHub API rate limit reached")
 )
 
 //go:generate go run github.com/vburenin/ifacemaker --file $GOFILE --struct client --iface Client --pkg github --output github_iface.go
 type client struct {
 	c      *graphql.Client
 	logger *slog.Logger
+
+	login       string // user whose stars are fetched, the viewer if empty
+	viewerMu    sync.Mutex
+	viewerLogin string // cached login of the viewer, guarded by viewerMu
+
+	maxRetries int
+	retryDelay time.Duration
+
+	readmeCandidates []string
+	readmeCode       bool
+
+	rateLimit atomic.Pointer[RateLimit] // last known rate limit state
 }
 
 type config struct {
@@ -40,10 +63,30 @@ type config struct {
 	HTTPClient *http.Client
 
 	// GraphqlEndpoint is the GitHub GraphQL API endpoint.
+	// A GitHub Enterprise Server host without path (e.g. https://github.example.com) is resolved to its GraphQL API path.
+	// GitHub Enterprise Server 3.0 or later is required, star lists are only indexed when the instance supports them.
 	Endpoint string
 
 	// Logger is the logger to use.
 	Logger *slog.Logger
+
+	// Login is the user whose stars are fetched.
+	// Defaults to the user owning the token.
+	Login string
+
+	// MaxRetries is the number of times a failed query is retried before giving up.
+	MaxRetries int
+
+	// RetryDelay is the base delay of the exponential backoff between retries.
+	RetryDelay time.Duration
+
+	// ReadmeCandidates are the readme paths tried in order, relative to the repository root.
+	// Defaults to the comma separated README_CANDIDATES environment variable, DefaultReadmeCandidates otherwise.
+	ReadmeCandidates []string
+
+	// ReadmeCode keeps the code blocks of the readmes in their own field instead of dropping them.
+	// Defaults to the README_CODE environment variable.
+	ReadmeCode bool
 }
 
 func defaultEnvs(values []string, def string) string {
@@ -56,12 +99,33 @@ func defaultEnvs(values []string, def string) string {
 	return def
 }
 
+// splitList splits the comma separated values of v, returns def if there is none.
+func splitList(v string, def []string) []string {
+	values := make([]string, 0)
+	for _, vv := range strings.Split(v, ",") {
+		if vv = strings.TrimSpace(vv); vv != "" {
+			values = append(values, vv)
+		}
+	}
+
+	if len(values) == 0 {
+		return def
+	}
+
+	return values
+}
+
 func newDefaultConfig() *config {
 	return &config{
 		Token:      defaultEnvs([]string{"GITHUB_TOKEN", "GH_TOKEN"}, ""),
 		HTTPClient: http.DefaultClient,
 		Endpoint:   defaultEnvs([]string{"GITHUB_GRAPHQL_ENDPOINT", "GH_GRAPHQL_ENDPOINT"}, GraphqlEndpoint),
 		Logger:     slog.Default(),
+		MaxRetries: 5,
+		RetryDelay: time.Second,
+
+		ReadmeCandidates: splitList(defaultEnvs([]string{"README_CANDIDATES"}, ""), DefaultReadmeCandidates),
+		ReadmeCode:       defaultEnvs([]string{"README_CODE"}, "") == "true",
 	}
 }
 
@@ -74,9 +138,40 @@ func (c *config) validate() error {
 		return ErrMissingEndpoint
 	}
 
+	endpoint, err := resolveEndpoint(c.Endpoint)
+	if err != nil {
+		return err
+	}
+
+	c.Endpoint = endpoint
 	return nil
 }
 
+// resolveEndpoint returns the GraphQL API URL of the given endpoint.
+// An endpoint without path is considered as the host of the GitHub instance:
+// github.com uses GraphqlEndpoint, GitHub Enterprise Server exposes its API under /api/graphql.
+func resolveEndpoint(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", ErrInvalidEndpoint, err)
+	}
+
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("%w: %s", ErrInvalidEndpoint, endpoint)
+	}
+
+	if strings.Trim(u.Path, "/") != "" {
+		return endpoint, nil
+	}
+
+	switch u.Host {
+	case "github.com", "api.github.com":
+		return GraphqlEndpoint, nil
+	default:
+		return u.JoinPath("api", "graphql").String(), nil
+	}
+}
+
 // Option is a client option.
 type Option func(*config)
 
@@ -108,6 +203,43 @@ func WithLogger(logger *slog.Logger) Option {
 	}
 }
 
+// WithLogin sets the user whose stars are fetched instead of the user owning the token.
+// Only the public stars are visible, unless the token belongs to this user.
+func WithLogin(login string) Option {
+	return func(c *config) {
+		c.Login = login
+	}
+}
+
+// WithMaxRetries sets the number of times a failed query is retried before giving up.
+func WithMaxRetries(maxRetries int) Option {
+	return func(c *config) {
+		c.MaxRetries = maxRetries
+	}
+}
+
+// WithRetryDelay sets the base delay of the exponential backoff between retries.
+func WithRetryDelay(delay time.Duration) Option {
+	return func(c *config) {
+		c.RetryDelay = delay
+	}
+}
+
+// WithReadmeCandidates sets the readme paths tried in order, relative to the repository root.
+// Root files are matched case-insensitively.
+func WithReadmeCandidates(candidates ...string) Option {
+	return func(c *config) {
+		c.ReadmeCandidates = candidates
+	}
+}
+
+// WithReadmeCode keeps the code blocks of the readmes in their own field instead of dropping them.
+func WithReadmeCode(enabled bool) Option {
+	return func(c *config) {
+		c.ReadmeCode = enabled
+	}
+}
+
 // New creates a new GitHub API client.
 // It uses the GITHUB_TOKEN environment variable for authentication.
 func New(ctx context.Context, opts ...Option) (Client, error) {
@@ -120,68 +252,272 @@ func New(ctx context.Context, opts ...Option) (Client, error) {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
 
+	conf.Logger.Debug("using GitHub GraphQL API endpoint " + conf.Endpoint)
 	src := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: conf.Token})
 	httpClient := oauth2.NewClient(context.WithValue(ctx, oauth2.HTTPClient, conf.HTTPClient), src)
 
 	return &client{
-		c:      graphql.NewClient(GraphqlEndpoint, httpClient),
-		logger: conf.Logger,
+		c:          graphql.NewClient(conf.Endpoint, httpClient),
+		logger:     conf.Logger,
+		login:      conf.Login,
+		maxRetries: conf.MaxRetries,
+		retryDelay: conf.RetryDelay,
+
+		readmeCandidates: conf.ReadmeCandidates,
+		readmeCode:       conf.ReadmeCode,
 	}, nil
 }
 
+// Login returns the login of the user whose stars are fetched.
+func (c *client) Login(ctx context.Context) (string, error) {
+	if c.login != "" {
+		return c.login, nil
+	}
+
+	// a failed lookup is retried by the next call
+	c.viewerMu.Lock()
+	defer c.viewerMu.Unlock()
+
+	if c.viewerLogin == "" {
+		var q viewerQuery
+		if err := c.query(ctx, &q, nil); err != nil {
+			return "", fmt.Errorf("failed to get viewer login: %w", err)
+		}
+
+		c.viewerLogin = q.Viewer.Login
+	}
+
+	return c.viewerLogin, nil
+}
+
 // GetStars returns the list of repositories starred by the user.
-func (c *client) GetStars(ctx context.Context) <-chan *StarredRepository {
+// See GetStarsSince for the errors handling.
+func (c *client) GetStars(ctx context.Context) (<-chan *StarredRepository, <-chan error) {
+	return c.GetStarsSince(ctx, time.Time{}, "")
+}
+
+// GetStarsSince returns the list of repositories starred by the user since the given time.
+// Stars are returned from the most recently starred to the oldest one,
+// paging stops as soon as a star older than since is reached.
+// A zero since returns all the stars.
+// A non-empty cursor resumes the listing after the star having this cursor.
+//
+// When the rate limit is about to be reached, the listing waits for its reset before resuming.
+// The error channel receives at most one error once the stars channel is closed.
+// A non-nil error means the listing is incomplete.
+func (c *client) GetStarsSince(ctx context.Context, since time.Time, cursor string) (<-chan *StarredRepository, <-chan error) {
 	out := make(chan *StarredRepository)
+	errs := make(chan error, 1)
 
 	vars := map[string]any{
 		"count":  100,
-		"cursor": "",
+		"cursor": cursor,
 	}
 
 	go func() {
+		defer close(errs)
 		defer close(out)
 
 		for {
-			var q query
-			if err := c.c.Query(ctx, &q, vars); err != nil {
-				c.logger.With(slogx.Err(err)).Error("failed to query")
-				continue
+			user, err := c.queryStars(ctx, vars)
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			repos := user.StarredRepositories.Repositories
+			// the page is not indexed without its readmes, the listing is resumed at it by the next sync
+			if err := c.resolveReadmes(ctx, newerStars(repos, since)); err != nil {
+				errs <- err
+				return
 			}
 
-			for _, repo := range q.Viewer.StarredRepositories.Repositories {
-				c.parseReadme(repo)
-				out <- repo
+			for _, repo := range repos {
+				if repo.StarredAt.Before(since) {
+					c.logger.Debug(fmt.Sprintf("reached already known stars (starred before %s)", since))
+					return
+				}
+
+				c.parseTopics(repo)
+				repo.Repository.StarredAt = repo.StarredAt
+				select {
+				case out <- repo:
+				case <-ctx.Done():
+					errs <- ctx.Err()
+					return
+				}
 			}
 
-			if !q.Viewer.StarredRepositories.PageInfo.HasNextPage {
+			if !user.StarredRepositories.PageInfo.HasNextPage {
 				break
 			}
 
-			// handle rate limit
-			if q.RateLimit.Remaining <= q.RateLimit.Used+10 {
-				wait := time.Until(q.RateLimit.ResetAt)
-				c.logger.
-					With(slog.Any("rate_limit", q.RateLimit)).
-					Warn(fmt.Sprintf("rate limit reached (with 10 units buffer), will be reset in %s", wait))
+			vars["cursor"] = user.StarredRepositories.PageInfo.EndCursor
+			// the readmes query of the page spent points too, the last recorded state accounts for them
+			if err := c.waitRateLimit(ctx, c.RateLimit()); err != nil {
+				errs <- err
 				return
 			}
-
-			vars["cursor"] = q.Viewer.StarredRepositories.PageInfo.EndCursor
 		}
 	}()
 
-	return out
+	return out, errs
+}
+
+// queryStars fetches a page of stars of the configured user, or of the viewer if none.
+func (c *client) queryStars(ctx context.Context, vars map[string]any) (*stargazer, error) {
+	if c.login == "" {
+		var q query
+		if err := c.query(ctx, &q, vars); err != nil {
+			return nil, err
+		}
+
+		c.setRateLimit(q.RateLimit)
+		return &q.Viewer, nil
+	}
+
+	vars["login"] = c.login
+	var q userQuery
+	if err := c.query(ctx, &q, vars); err != nil {
+		return nil, err
+	}
+
+	c.setRateLimit(q.RateLimit)
+	return &q.User, nil
+}
+
+// setRateLimit records the rate limit state returned by a query, if any.
+func (c *client) setRateLimit(rateLimit *RateLimit) {
+	if rateLimit != nil {
+		c.rateLimit.Store(rateLimit)
+	}
+}
+
+// RateLimit returns the rate limit state returned by the last query, nil if none has been made.
+func (c *client) RateLimit() *RateLimit {
+	return c.rateLimit.Load()
+}
+
+// waitRateLimit blocks until the rate limit is reset if there are not enough points left for the next query.
+func (c *client) waitRateLimit(ctx context.Context, rateLimit *RateLimit) error {
+	if rateLimit == nil || rateLimit.Remaining > rateLimit.Cost+rateLimitBuffer {
+		return nil
+	}
+
+	wait := time.Until(rateLimit.ResetAt)
+	c.logger.
+		With(slog.Any("rate_limit", rateLimit)).
+		Warn(fmt.Sprintf("rate limit reached (with %d points buffer), waiting %s for its reset", rateLimitBuffer, wait))
+
+	select {
+	case <-time.After(wait):
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("%w, was waiting for its reset: %w", ErrRateLimited, ctx.Err())
+	}
+}
+
+// GetLists returns the star lists of the user, with the IDs of the repositories they contain.
+func (c *client) GetLists(ctx context.Context) ([]*List, error) {
+	lists := make([]*List, 0)
+	vars := map[string]any{
+		"count":  100,
+		"cursor": "",
+	}
+
+	for {
+		owner, err := c.queryLists(ctx, vars)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get lists: %w", err)
+		}
+
+		for _, node := range owner.Lists.Nodes {
+			list := &List{ID: node.ID, Name: node.Name, Slug: node.Slug}
+			if err := c.getListItems(ctx, list, node.Items); err != nil {
+				return nil, err
+			}
+
+			lists = append(lists, list)
+		}
+
+		// lists resolved with an error, see optionalFields
+		if owner.Lists.PageInfo == nil || !owner.Lists.PageInfo.HasNextPage {
+			return lists, nil
+		}
+
+		vars["cursor"] = owner.Lists.PageInfo.EndCursor
+	}
+}
+
+// queryLists fetches a page of lists of the configured user, or of the viewer if none.
+func (c *client) queryLists(ctx context.Context, vars map[string]any) (*listsOwner, error) {
+	if c.login == "" {
+		var q listsQuery
+		if err := c.query(ctx, &q, vars); err != nil {
+			return nil, err
+		}
+
+		c.setRateLimit(q.RateLimit)
+		return &q.Viewer, nil
+	}
+
+	vars["login"] = c.login
+	var q userListsQuery
+	if err := c.query(ctx, &q, vars); err != nil {
+		return nil, err
+	}
+
+	c.setRateLimit(q.RateLimit)
+	return &q.User, nil
 }
 
-// parseReadme parses the readme from the repository.
-// Tries to parse README from all possible locations.
-func (c *client) parseReadme(repo *StarredRepository) {
-	readmes := []*readme{repo.Repository.R1, repo.Repository.R2}
-	for _, r := range readmes {
-		if r == nil {
-			continue
+// getListItems adds the repositories of the given items page to the list, then fetches the next pages.
+func (c *client) getListItems(ctx context.Context, list *List, items listItems) error {
+	for {
+		for _, node := range items.Nodes {
+			if node.Repository.ID != "" { // skip items which are not repositories
+				list.RepositoryIDs = append(list.RepositoryIDs, node.Repository.ID)
+			}
+		}
+
+		if items.PageInfo == nil || !items.PageInfo.HasNextPage {
+			return nil
+		}
+
+		var q listItemsQuery
+		vars := map[string]any{
+			"id":     graphql.ID(list.ID),
+			"count":  100,
+			"cursor": items.PageInfo.EndCursor,
+		}
+
+		if err := c.query(ctx, &q, vars); err != nil {
+			return fmt.Errorf("failed to get items of list %s: %w", list.Slug, err)
+		}
+
+		c.setRateLimit(q.RateLimit)
+		items = q.Node.UserList.Items
+	}
+}
+
+// newerStars returns the leading stars of the page which are not older than since.
+func newerStars(repos []*StarredRepository, since time.Time) []*StarredRepository {
+	for i, repo := range repos {
+		if repo.StarredAt.Before(since) {
+			return repos[:i]
 		}
+	}
+
+	return repos
+}
 
-		repo.Repository.Readme = r.Blob.Text
+// parseTopics flattens the repository topics names.
+func (c *client) parseTopics(repo *StarredRepository) {
+	nodes := repo.Repository.RepositoryTopics.Nodes
+	topics := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		topics = append(topics, node.Topic.Name)
 	}
+
+	repo.Repository.Topics = topics
 }
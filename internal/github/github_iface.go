@@ -4,10 +4,28 @@ package github
 
 import (
 	"context"
+	"time"
 )
 
 // Client ...
 type Client interface {
+	// Login returns the login of the user whose stars are fetched.
+	Login(ctx context.Context) (string, error)
 	// GetStars returns the list of repositories starred by the user.
-	GetStars(ctx context.Context) <-chan *StarredRepository
+	// See GetStarsSince for the errors handling.
+	GetStars(ctx context.Context) (<-chan *StarredRepository, <-chan error)
+	// GetStarsSince returns the list of repositories starred by the user since the given time.
+	// Stars are returned from the most recently starred to the oldest one,
+	// paging stops as soon as a star older than since is reached.
+	// A zero since returns all the stars.
+	// A non-empty cursor resumes the listing after the star having this cursor.
+	//
+	// When the rate limit is about to be reached, the listing waits for its reset before resuming.
+	// The error channel receives at most one error once the stars channel is closed.
+	// A non-nil error means the listing is incomplete.
+	GetStarsSince(ctx context.Context, since time.Time, cursor string) (<-chan *StarredRepository, <-chan error)
+	// RateLimit returns the rate limit state returned by the last query, nil if none has been made.
+	RateLimit() *RateLimit
+	// GetLists returns the star lists of the user, with the IDs of the repositories they contain.
+	GetLists(ctx context.Context) ([]*List, error)
 }
@@ -0,0 +1,433 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testToken is the API token expected by the fake GraphQL server.
+const testToken string = "test-token"
+
+// viewerPayload answers the viewer login query.
+const viewerPayload string = `{"data": {"viewer": {"login": "octocat"}}}`
+
+// starsPayload answers the stars query with a single page of one star.
+const starsPayload string = `{
+  "data": {
+    "viewer": {
+      "login": "octocat",
+      "starredRepositories": {
+        "totalCount": 1,
+        "pageInfo": {"endCursor": "Y3Vyc29yOjE=", "hasNextPage": false},
+        "edges": [{
+          "cursor": "Y3Vyc29yOjE=",
+          "starredAt": "2024-05-01T10:00:00Z",
+          "node": {
+            "id": "R_1",
+            "nameWithOwner": "blevesearch/bleve",
+            "description": "A modern text indexing library for go",
+            "url": "https://github.com/blevesearch/bleve",
+            "defaultBranchRef": {
+              "name": "master",
+              "target": {"tree": {"entries": [{"name": "README.md", "type": "blob"}]}}
+            },
+            "repositoryTopics": {"nodes": [{"topic": {"name": "search"}}, {"topic": {"name": "go"}}]},
+            "stargazerCount": 10000
+          }
+        }]
+      }
+    },
+    "rateLimit": {"cost": 1, "limit": 5000, "remaining": 4999, "used": 1, "resetAt": "2024-05-01T11:00:00Z"}
+  }
+}`
+
+// readmesPayload answers the readmes query of the star of starsPayload.
+const readmesPayload string = `{
+  "data": {
+    "r0": {"c0": {"text": "# Bleve\n\nFull-text search for Go."}},
+    "rateLimit": {"cost": 1, "limit": 5000, "remaining": 4998, "used": 2, "resetAt": "2024-05-01T11:00:00Z"}
+  }
+}`
+
+// starsWithoutRateLimitPayload answers the stars query like a GitHub Enterprise Server with rate limiting disabled.
+const starsWithoutRateLimitPayload string = `{
+  "data": {
+    "viewer": {
+      "login": "octocat",
+      "starredRepositories": {
+        "totalCount": 1,
+        "pageInfo": {"endCursor": "Y3Vyc29yOjE=", "hasNextPage": false},
+        "edges": [{
+          "cursor": "Y3Vyc29yOjE=",
+          "starredAt": "2024-05-01T10:00:00Z",
+          "node": {"id": "R_1", "nameWithOwner": "blevesearch/bleve", "url": "https://github.com/blevesearch/bleve"}
+        }]
+      }
+    },
+    "rateLimit": null
+  },
+  "errors": [{"message": "Rate limiting is not enabled on this instance.", "path": ["rateLimit"]}]
+}`
+
+// listsDisabledPayload answers the lists query like a GitHub Enterprise Server with star lists disabled.
+const listsDisabledPayload string = `{
+  "data": {"viewer": null, "rateLimit": null},
+  "errors": [
+    {"message": "Star lists are not enabled on this instance.", "path": ["viewer", "lists"]},
+    {"message": "Rate limiting is not enabled on this instance.", "path": ["rateLimit"]}
+  ]
+}`
+
+// newTestServer returns a fake GitHub Enterprise Server GraphQL API answering the stars query with the given payload,
+// the readmes query, the lists query and the viewer login query.
+// It fails the test on requests to another path or without the test token, and counts the viewer queries.
+func newTestServer(t *testing.T, stars string, viewerQueries *atomic.Int32) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/graphql" {
+			t.Errorf("request path = %q, want /api/graphql", r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+
+		if got := r.Header.Get("Authorization"); got != "Bearer "+testToken {
+			t.Errorf("Authorization header = %q, want %q", got, "Bearer "+testToken)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		var body struct {
+			Query string `json:"query"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.Contains(body.Query, "starredRepositories"):
+			_, _ = io.WriteString(w, stars)
+		case strings.Contains(body.Query, "r0:"):
+			_, _ = io.WriteString(w, readmesPayload)
+		case strings.Contains(body.Query, "lists("):
+			_, _ = io.WriteString(w, listsDisabledPayload)
+		case strings.Contains(body.Query, "viewer"):
+			viewerQueries.Add(1)
+			_, _ = io.WriteString(w, viewerPayload)
+		default:
+			t.Errorf("unexpected query %q", body.Query)
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newTestClient returns a client of the given fake server.
+func newTestClient(t *testing.T, srv *httptest.Server) Client {
+	t.Helper()
+
+	c, err := New(
+		context.Background(),
+		WithToken(testToken),
+		WithEndpoint(srv.URL), // without path, resolved as a GitHub Enterprise Server
+		WithHTTPClient(srv.Client()),
+		WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+		WithMaxRetries(0),
+		WithReadmeCandidates(DefaultReadmeCandidates...),
+	)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	return c
+}
+
+// collectStars drains the stars listing, failing the test on a listing error.
+func collectStars(t *testing.T, stars <-chan *StarredRepository, errs <-chan error) []*StarredRepository {
+	t.Helper()
+
+	repos := make([]*StarredRepository, 0)
+	for star := range stars {
+		repos = append(repos, star)
+	}
+
+	if err := <-errs; err != nil {
+		t.Fatalf("GetStars() error = %v", err)
+	}
+
+	return repos
+}
+
+func TestResolveEndpoint(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		want     string
+		wantErr  error
+	}{
+		{endpoint: "https://github.com", want: GraphqlEndpoint},
+		{endpoint: "https://api.github.com/", want: GraphqlEndpoint},
+		{endpoint: "https://github.example.com", want: "https://github.example.com/api/graphql"},
+		{endpoint: "https://github.example.com/custom/graphql", want: "https://github.example.com/custom/graphql"},
+		{endpoint: "ftp://github.example.com", wantErr: ErrInvalidEndpoint},
+		{endpoint: "github.example.com", wantErr: ErrInvalidEndpoint},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.endpoint, func(t *testing.T) {
+			got, err := resolveEndpoint(tt.endpoint)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("resolveEndpoint() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("resolveEndpoint() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientLogin(t *testing.T) {
+	var viewerQueries atomic.Int32
+	c := newTestClient(t, newTestServer(t, starsPayload, &viewerQueries))
+
+	for i := 0; i < 2; i++ {
+		login, err := c.Login(context.Background())
+		if err != nil {
+			t.Fatalf("Login() error = %v", err)
+		}
+
+		if login != "octocat" {
+			t.Errorf("Login() = %q, want octocat", login)
+		}
+	}
+
+	if got := viewerQueries.Load(); got != 1 {
+		t.Errorf("viewer queries = %d, want 1, the login is cached", got)
+	}
+}
+
+func TestClientGetStars(t *testing.T) {
+	c := newTestClient(t, newTestServer(t, starsPayload, new(atomic.Int32)))
+	stars, errs := c.GetStars(context.Background())
+	repos := collectStars(t, stars, errs)
+	if len(repos) != 1 {
+		t.Fatalf("GetStars() = %d stars, want 1", len(repos))
+	}
+
+	repo := repos[0].Repository
+	starredAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	if repo.ID != "R_1" || repo.NameWithOwner != "blevesearch/bleve" || !repos[0].StarredAt.Equal(starredAt) {
+		t.Errorf("GetStars() repository = %s %s starred at %s, want R_1 blevesearch/bleve starred at %s",
+			repo.ID, repo.NameWithOwner, repos[0].StarredAt, starredAt)
+	}
+
+	if !slices.Equal(repo.Topics, []string{"search", "go"}) {
+		t.Errorf("GetStars() topics = %v, want [search go]", repo.Topics)
+	}
+
+	if !strings.Contains(repo.Readme, "Full-text search for Go.") || !slices.Equal(repo.ReadmeHeadings, []string{"Bleve"}) {
+		t.Errorf("GetStars() readme = %q with headings %v, want the README.md content", repo.Readme, repo.ReadmeHeadings)
+	}
+
+	// the readmes query is the last one
+	if rateLimit := c.RateLimit(); rateLimit == nil || rateLimit.Remaining != 4998 {
+		t.Errorf("RateLimit() = %+v, want 4998 remaining points", rateLimit)
+	}
+}
+
+func TestClientGetStarsWithoutRateLimit(t *testing.T) {
+	c := newTestClient(t, newTestServer(t, starsWithoutRateLimitPayload, new(atomic.Int32)))
+	stars, errs := c.GetStars(context.Background())
+	repos := collectStars(t, stars, errs)
+	if len(repos) != 1 || repos[0].Repository.ID != "R_1" {
+		t.Fatalf("GetStars() = %v, want the star R_1 despite the rateLimit error", repos)
+	}
+}
+
+func TestClientGetListsDisabled(t *testing.T) {
+	c := newTestClient(t, newTestServer(t, starsPayload, new(atomic.Int32)))
+	lists, err := c.GetLists(context.Background())
+	if err != nil {
+		t.Fatalf("GetLists() error = %v", err)
+	}
+
+	if len(lists) != 0 {
+		t.Errorf("GetLists() = %v, want no list", lists)
+	}
+}
+
+// starEdge returns a star of a stars page, the repository having a README.md if readme is true.
+func starEdge(id, cursor string, starredAt time.Time, readme bool) string {
+	branch := "null"
+	if readme {
+		branch = `{"name": "main", "target": {"tree": {"entries": [{"name": "README.md", "type": "blob"}]}}}`
+	}
+
+	return fmt.Sprintf(`{"cursor": %q, "starredAt": %q, "node": {"id": %q, "nameWithOwner": "octocat/%s", "defaultBranchRef": %s}}`,
+		cursor, starredAt.Format(time.RFC3339), id, id, branch)
+}
+
+// starsPagePayload answers the stars query with the given stars, followed by another page if hasNextPage is true.
+func starsPagePayload(rateLimit string, hasNextPage bool, edges ...string) string {
+	endCursor := ""
+	if len(edges) > 0 {
+		var edge struct{ Cursor string }
+		_ = json.Unmarshal([]byte(edges[len(edges)-1]), &edge)
+		endCursor = edge.Cursor
+	}
+
+	return fmt.Sprintf(`{"data": {"viewer": {"login": "octocat", "starredRepositories": {
+	  "totalCount": %d, "pageInfo": {"endCursor": %q, "hasNextPage": %t}, "edges": [%s]}}, "rateLimit": %s}}`,
+		len(edges), endCursor, hasNextPage, strings.Join(edges, ","), rateLimit)
+}
+
+// pagedServer is a fake GraphQL API answering the stars query with a page by cursor.
+type pagedServer struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	cursors []string    // cursor of each stars query, in order
+	times   []time.Time // time of each stars query
+}
+
+// newPagedServer returns a fake GraphQL API answering the stars query with the page of the requested cursor,
+// and the readmes query with the given function.
+func newPagedServer(t *testing.T, pages map[string]string, readmes func() string) *pagedServer {
+	t.Helper()
+
+	srv := &pagedServer{}
+	srv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Query     string         `json:"query"`
+			Variables map[string]any `json:"variables"`
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.Contains(body.Query, "starredRepositories"):
+			cursor, _ := body.Variables["cursor"].(string)
+			srv.mu.Lock()
+			srv.cursors = append(srv.cursors, cursor)
+			srv.times = append(srv.times, time.Now())
+			srv.mu.Unlock()
+
+			page, ok := pages[cursor]
+			if !ok {
+				t.Errorf("unexpected stars cursor %q", cursor)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			_, _ = io.WriteString(w, page)
+		case strings.Contains(body.Query, "r0:"):
+			_, _ = io.WriteString(w, readmes())
+		default:
+			t.Errorf("unexpected query %q", body.Query)
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// requests returns the cursors and times of the received stars queries.
+func (s *pagedServer) requests() ([]string, []time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Clone(s.cursors), slices.Clone(s.times)
+}
+
+func TestClientGetStarsWaitsRateLimit(t *testing.T) {
+	starredAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	fullRateLimit := `{"cost": 1, "limit": 5000, "remaining": 4999, "used": 1, "resetAt": "2024-05-01T11:00:00Z"}`
+	pages := map[string]string{
+		"":   starsPagePayload(fullRateLimit, true, starEdge("R_1", "c1", starredAt, true)),
+		"c1": starsPagePayload(fullRateLimit, false, starEdge("R_2", "c2", starredAt.Add(-time.Hour), false)),
+	}
+
+	// the readmes query of the first page spends the last points, the next page waits for the reset
+	reset := 300 * time.Millisecond
+	var readmesAt atomic.Int64
+	srv := newPagedServer(t, pages, func() string {
+		now := time.Now()
+		readmesAt.Store(now.UnixNano())
+		return fmt.Sprintf(`{"data": {"r0": {"c0": {"text": "# R_1"}},
+		  "rateLimit": {"cost": 1, "limit": 5000, "remaining": 5, "used": 4995, "resetAt": %q}}}`,
+			now.Add(reset).Format(time.RFC3339Nano))
+	})
+
+	c := newTestClient(t, srv.Server)
+	stars, errs := c.GetStars(context.Background())
+	if repos := collectStars(t, stars, errs); len(repos) != 2 {
+		t.Fatalf("GetStars() = %d stars, want 2", len(repos))
+	}
+
+	cursors, times := srv.requests()
+	if !slices.Equal(cursors, []string{"", "c1"}) {
+		t.Fatalf("stars queries cursors = %q, want [\"\" c1]", cursors)
+	}
+
+	if waited := times[1].Sub(time.Unix(0, readmesAt.Load())); waited < reset/2 {
+		t.Errorf("next page queried %s after the readmes, want it to wait about %s for the rate limit reset", waited, reset)
+	}
+}
+
+func TestClientGetStarsSince(t *testing.T) {
+	starredAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	rateLimit := `{"cost": 1, "limit": 5000, "remaining": 4999, "used": 1, "resetAt": "2024-05-01T11:00:00Z"}`
+	pages := map[string]string{
+		"": starsPagePayload(rateLimit, true,
+			starEdge("R_3", "c3", starredAt, false),
+			starEdge("R_2", "c2", starredAt.Add(-time.Hour), false),
+			starEdge("R_1", "c1", starredAt.Add(-2*time.Hour), false),
+		),
+		"c1": starsPagePayload(rateLimit, false, starEdge("R_0", "c0", starredAt.Add(-3*time.Hour), false)),
+	}
+
+	srv := newPagedServer(t, pages, func() string {
+		t.Error("unexpected readmes query")
+		return "{}"
+	})
+
+	// the stars starred before since are already indexed, paging stops at the first of them
+	c := newTestClient(t, srv.Server)
+	stars, errs := c.GetStarsSince(context.Background(), starredAt.Add(-time.Hour), "")
+	ids := make([]string, 0)
+	for _, repo := range collectStars(t, stars, errs) {
+		ids = append(ids, repo.Repository.ID)
+	}
+
+	if !slices.Equal(ids, []string{"R_3", "R_2"}) {
+		t.Errorf("GetStarsSince() = %v, want [R_3 R_2]", ids)
+	}
+
+	if cursors, _ := srv.requests(); !slices.Equal(cursors, []string{""}) {
+		t.Errorf("stars queries cursors = %q, want only the first page", cursors)
+	}
+}
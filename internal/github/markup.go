@@ -0,0 +1,196 @@
+package github
+
+import (
+	"html"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// markup describes how to convert a readme markup to plain text.
+type markup struct {
+	code         []*regexp.Regexp // code blocks, the first group is the code
+	headings     []*regexp.Regexp // section titles, the first group is the title
+	replacements []markupReplacement
+}
+
+// markupReplacement replaces the matches of a pattern, in order, when converting a markup to plain text.
+type markupReplacement struct {
+	pattern *regexp.Regexp
+	repl    string
+}
+
+// markdownReplacements converts Markdown and its inline HTML to plain text.
+// Images, badges and link targets are dropped.
+var markdownReplacements = []markupReplacement{
+	{regexp.MustCompile(`(?s)<!--.*?-->`), ""},                                                      // comments
+	{regexp.MustCompile(`(?i)<(img|source)\b[^>]*>`), ""},                                           // images
+	{regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|h[1-6]|li|tr|table|ul|ol|details|summary)>`), "\n"}, // block tags
+	{regexp.MustCompile(`<[^>\n]+>`), ""},                                                           // other tags and autolinks
+	{regexp.MustCompile(`\[!\[[^\]]*\]\([^)]*\)\]\([^)]*\)`), ""},                                   // linked images, mostly badges
+	{regexp.MustCompile(`!\[[^\]]*\](\([^)]*\)|\[[^\]]*\])`), ""},                                   // images
+	{regexp.MustCompile(`(?m)^ {0,3}\[[^\]]+\]:[ \t]*\S.*$`), ""},                                   // link reference definitions
+	{regexp.MustCompile(`\[([^\]]*)\](\([^)]*\)|\[[^\]]*\])`), "$1"},                                // links
+	{regexp.MustCompile(`https?://[^\s)>\]]+`), ""},                                                 // bare URLs
+	{regexp.MustCompile(`(?m)^ {0,3}#{1,6}[ \t]+(.+?)[ \t#]*$`), "$1"},                              // ATX headings
+	{regexp.MustCompile(`(?m)^ {0,3}(=+|-+|([*_][ \t]*){3,})[ \t]*$`), ""},                          // setext underlines and rules
+	{regexp.MustCompile(`(?m)^[ \t]*\|?([ \t]*:?-+:?[ \t]*\|)+([ \t]*:?-+:?[ \t]*)?$`), ""},         // table delimiter rows
+	{regexp.MustCompile(`\|`), " "},                                                                 // table cells
+	{regexp.MustCompile(`(?m)^ {0,3}>[ \t]?`), ""},                                                  // block quotes
+	{regexp.MustCompile(`(?m)^[ \t]*([-*+]|\d+[.)])[ \t]+(\[[ xX]\][ \t]+)?`), ""},                  // list items and task lists
+	{regexp.MustCompile("`([^`\n]+)`"), "$1"},                                                       // inline code
+	{regexp.MustCompile(`(\*\*|__)([^\n]+?)(\*\*|__)`), "$2"},                                       // strong emphasis
+	{regexp.MustCompile(`(^|[^\w*])\*([^*\n]+)\*`), "$1$2"},                                         // emphasis
+	{regexp.MustCompile(`(^|\W)_([^_\n]+)_(\W|$)`), "$1$2$3"},                                       // emphasis
+	{regexp.MustCompile(`~~([^~\n]+)~~`), "$1"},                                                     // strikethrough
+}
+
+// rstReplacements converts reStructuredText to plain text.
+var rstReplacements = []markupReplacement{
+	{regexp.MustCompile(`(?m)^\.\. [\w:-]+::.*$`), ""}, // directives, such as images
+	{regexp.MustCompile(`(?m)^\s+:[\w-]+:.*$`), ""},    // directive options
+	{regexp.MustCompile(`(?m)^\.\.( .*)?$`), ""},       // comments and link targets
+	{regexp.MustCompile("(?m)^(={3,}|-{3,}|~{3,}|\\^{3,}|\"{3,}|'{3,}|`{3,}|#{3,}|\\*{3,}|\\+{3,}|\\.{3,}|:{3,}|_{3,})\\s*$"), ""}, // section adornments
+	{regexp.MustCompile("`([^`<]+?)\\s*<[^>]+>`__?"), "$1"},                                                                        // hyperlinks
+	{regexp.MustCompile("``([^`]+)``"), "$1"},                                                                                      // inline literals
+	{regexp.MustCompile("(?::[\\w-]+:)?`([^`]+)`(?:__?|:[\\w-]+:)?"), "$1"},                                                        // roles and references
+	{regexp.MustCompile(`\*\*([^*]+)\*\*`), "$1"},                                                                                  // strong emphasis
+	{regexp.MustCompile(`\*([^*\s][^*]*)\*`), "$1"},                                                                                // emphasis
+	{regexp.MustCompile(`\|[^|\s][^|]*\|_?`), ""},                                                                                  // substitutions, mostly badges
+	{regexp.MustCompile(`(?m)::\s*$`), ":"},                                                                                        // literal block markers
+}
+
+// asciiDocReplacements converts AsciiDoc to plain text.
+var asciiDocReplacements = []markupReplacement{
+	{regexp.MustCompile(`(?m)^:[\w-]+!?:.*$`), ""},                                      // attribute entries
+	{regexp.MustCompile(`(?m)^(image|include|toc)::.*$`), ""},                           // block macros
+	{regexp.MustCompile(`(?m)^\[.*\]\s*$`), ""},                                         // block attributes
+	{regexp.MustCompile(`(?m)^(-{4,}|={4,}|\*{4,}|\.{4,}|_{4,}|\+{4,}|/{4,})\s*$`), ""}, // block delimiters
+	{regexp.MustCompile(`(?m)^=+\s+`), ""},                                              // section titles
+	{regexp.MustCompile(`image:[^\[\s]*\[[^\]]*\]`), ""},                                // inline images, mostly badges
+	{regexp.MustCompile(`(?:link:)?(https?://[^\[\s]+)\[\]`), "$1"},                     // links without text
+	{regexp.MustCompile(`(?:link:|xref:)?(?:https?://)?[^\[\s]+\[([^\]]+)\]`), "$1"},    // links with text
+	{regexp.MustCompile(`<<[^,>]+,\s*([^>]+)>>`), "$1"},                                 // cross references
+	{regexp.MustCompile(`\*\*?([^*\n]+)\*\*?`), "$1"},                                   // bold
+	{regexp.MustCompile(`(^|\W)__?([^_\n]+?)__?(\W|$)`), "$1$2$3"},                      // italic
+	{regexp.MustCompile("``?([^`\n]+)``?"), "$1"},                                       // monospace
+}
+
+// orgReplacements converts Org mode to plain text.
+var orgReplacements = []markupReplacement{
+	{regexp.MustCompile(`(?ms)^\s*:PROPERTIES:.*?:END:\s*$`), ""},            // property drawers
+	{regexp.MustCompile(`(?mi)^#\+(begin|end)_\w+.*$`), ""},                  // block delimiters
+	{regexp.MustCompile(`(?m)^#\+\w+:.*$`), ""},                              // keywords
+	{regexp.MustCompile(`(?m)^#( .*)?$`), ""},                                // comments
+	{regexp.MustCompile(`(?m)^\*+\s+`), ""},                                  // headlines
+	{regexp.MustCompile(`\[\[[^\]]+\]\[([^\]]+)\]\]`), "$1"},                 // links with description
+	{regexp.MustCompile(`\[\[([^\]]+)\]\]`), "$1"},                           // links
+	{regexp.MustCompile(`(^|\s)([*/=~+])(\S[^\n]*?\S|\S)([*/=~+])`), "$1$3"}, // emphasis
+}
+
+// markups are the supported readme markups by file extension.
+// Markdown is GitHub default markup, it is used for any other extension.
+var (
+	markdownMarkup = &markup{
+		code: []*regexp.Regexp{
+			regexp.MustCompile("(?ms)^ {0,3}```[^\n]*\n(.*?)^ {0,3}```[ \t]*$"),
+			regexp.MustCompile(`(?ms)^ {0,3}~~~[^\n]*\n(.*?)^ {0,3}~~~[ \t]*$`),
+			regexp.MustCompile(`(?is)<pre[^>]*>(.*?)</pre>`),
+		},
+		headings: []*regexp.Regexp{
+			regexp.MustCompile(`(?m)^ {0,3}#{1,6}[ \t]+(.+?)[ \t#]*$`),
+			regexp.MustCompile(`(?m)^ {0,3}([^\s<|>-][^\n]*)\n {0,3}(=+|-+)[ \t]*$`),
+			regexp.MustCompile(`(?is)<h[1-6][^>]*>(.*?)</h[1-6]>`),
+		},
+		replacements: markdownReplacements,
+	}
+
+	markups = map[string]*markup{
+		".rst": {
+			code: []*regexp.Regexp{
+				regexp.MustCompile(`(?m)^\.\. (?:code|code-block|sourcecode)::[^\n]*\n((?:[ \t]*\n|[ \t]+[^\n]*\n)*)`),
+			},
+			headings: []*regexp.Regexp{
+				regexp.MustCompile(`(?m)^([^\n]*\w[^\n]*)\n(={3,}|-{3,}|~{3,}|\^{3,}|\*{3,}|#{3,})[ \t]*$`),
+			},
+			replacements: rstReplacements,
+		},
+		".adoc": {
+			code: []*regexp.Regexp{
+				regexp.MustCompile(`(?ms)^-{4,}[ \t]*\n(.*?)^-{4,}[ \t]*$`),
+			},
+			headings: []*regexp.Regexp{
+				regexp.MustCompile(`(?m)^={1,6}[ \t]+(.+)$`),
+			},
+			replacements: asciiDocReplacements,
+		},
+		".org": {
+			code: []*regexp.Regexp{
+				regexp.MustCompile(`(?msi)^[ \t]*#\+begin_(?:src|example)[^\n]*\n(.*?)^[ \t]*#\+end_(?:src|example).*$`),
+			},
+			headings: []*regexp.Regexp{
+				regexp.MustCompile(`(?m)^\*+[ \t]+(.+)$`),
+			},
+			replacements: orgReplacements,
+		},
+	}
+)
+
+func init() {
+	markups[".rest"] = markups[".rst"]
+	markups[".asciidoc"] = markups[".adoc"]
+	markups[".asc"] = markups[".adoc"]
+}
+
+// blankLines matches the blank lines left by the removed markup.
+var blankLines = regexp.MustCompile(`\n\s*\n(\s*\n)+`)
+
+// readmeContent is a readme converted to plain text.
+type readmeContent struct {
+	Text     string   // text without the code blocks
+	Code     string   // code blocks, one per paragraph
+	Headings []string // section titles
+}
+
+// parseReadme converts the readme having the given file name to plain text according to its markup.
+// Code blocks and section titles are extracted apart.
+func parseReadme(name, text string) *readmeContent {
+	m, ok := markups[strings.ToLower(path.Ext(name))]
+	if !ok {
+		m = markdownMarkup
+	}
+
+	content := &readmeContent{Headings: make([]string, 0)}
+
+	code := make([]string, 0)
+	for _, pattern := range m.code {
+		text = pattern.ReplaceAllStringFunc(text, func(block string) string {
+			sub := pattern.FindStringSubmatch(block)
+			code = append(code, strings.TrimSpace(sub[1]))
+			return "\n"
+		})
+	}
+
+	content.Code = strings.Join(code, "\n\n")
+
+	for _, pattern := range m.headings {
+		for _, sub := range pattern.FindAllStringSubmatch(text, -1) {
+			if heading := m.plainText(sub[1]); heading != "" {
+				content.Headings = append(content.Headings, heading)
+			}
+		}
+	}
+
+	content.Text = m.plainText(text)
+	return content
+}
+
+// plainText applies the replacements of the markup to text.
+func (m *markup) plainText(text string) string {
+	for _, r := range m.replacements {
+		text = r.pattern.ReplaceAllString(text, r.repl)
+	}
+
+	text = html.UnescapeString(text)
+	return strings.TrimSpace(blankLines.ReplaceAllString(text, "\n\n"))
+}
@@ -0,0 +1,120 @@
+package github
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseReadmeMarkdown(t *testing.T) {
+	tests := []struct {
+		name         string
+		text         string
+		wantText     string
+		wantCode     string
+		wantHeadings []string
+	}{
+		{
+			name:     "badges and images",
+			text:     "[![Build](https://img.shields.io/b.svg)](https://ci.example.com) ![logo](logo.png) Fast search.",
+			wantText: "Fast search.",
+		},
+		{
+			name:     "links keep their text",
+			text:     "See [the docs](https://example.com/docs) and [the reference][1].\n\n[1]: https://example.com/ref",
+			wantText: "See the docs and the reference.",
+		},
+		{
+			name:     "fenced code",
+			text:     "Install:\n\n```go\ngo get example.com/pkg\n```\n\nDone.",
+			wantText: "Install:\n\nDone.",
+			wantCode: "go get example.com/pkg",
+		},
+		{
+			name:     "html tags",
+			text:     `<img src="logo.png"> Some <b>bold</b> text<br>then a <a href="https://example.com">link</a>`,
+			wantText: "Some bold text\nthen a link",
+		},
+		{
+			name:         "headings",
+			text:         "# Title\n\nText\n\n## Usage ##\n\nMore\n\nSetext\n------\n",
+			wantText:     "Title\n\nText\n\nUsage\n\nMore\n\nSetext",
+			wantHeadings: []string{"Title", "Usage", "Setext"},
+		},
+		{
+			name:     "plain text",
+			text:     "A plain sentence, nothing to strip.",
+			wantText: "A plain sentence, nothing to strip.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseReadme("README.md", tt.text)
+			if got.Text != tt.wantText {
+				t.Errorf("parseReadme() text = %q, want %q", got.Text, tt.wantText)
+			}
+
+			if got.Code != tt.wantCode {
+				t.Errorf("parseReadme() code = %q, want %q", got.Code, tt.wantCode)
+			}
+
+			if !slices.Equal(got.Headings, tt.wantHeadings) {
+				t.Errorf("parseReadme() headings = %q, want %q", got.Headings, tt.wantHeadings)
+			}
+		})
+	}
+}
+
+func TestParseReadmeMarkups(t *testing.T) {
+	tests := []struct {
+		name         string
+		text         string
+		wantText     string
+		wantCode     string
+		wantHeadings []string
+	}{
+		{
+			name:         "README.rst",
+			text:         "Title\n=====\n\n.. image:: https://img.shields.io/b.svg\n   :target: https://ci.example.com\n\nSee `the docs <https://example.com>`_ and ``code``.\n\n.. code-block:: python\n\n   import pkg\n\nEnd.",
+			wantText:     "Title\n\nSee the docs and code.\n\nEnd.",
+			wantCode:     "import pkg",
+			wantHeadings: []string{"Title"},
+		},
+		{
+			name:         "README.adoc",
+			text:         "= Title\n:toc:\n\nimage:https://img.shields.io/b.svg[Build]\n\n== Usage\n\nSee https://example.com[the docs] and *bold*.\n\n----\nmake build\n----\n",
+			wantText:     "Title\n\nUsage\n\nSee the docs and bold.",
+			wantCode:     "make build",
+			wantHeadings: []string{"Title", "Usage"},
+		},
+		{
+			name:         "README.org",
+			text:         "#+TITLE: pkg\n* Title\n\nSee [[https://example.com][the docs]] and *bold*.\n\n#+begin_src sh\nmake build\n#+end_src\n",
+			wantText:     "Title\n\nSee the docs and bold.",
+			wantCode:     "make build",
+			wantHeadings: []string{"Title"},
+		},
+		{
+			name:     "README.txt",
+			text:     "A plain sentence, nothing to strip.",
+			wantText: "A plain sentence, nothing to strip.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseReadme(tt.name, tt.text)
+			if got.Text != tt.wantText {
+				t.Errorf("parseReadme() text = %q, want %q", got.Text, tt.wantText)
+			}
+
+			if got.Code != tt.wantCode {
+				t.Errorf("parseReadme() code = %q, want %q", got.Code, tt.wantCode)
+			}
+
+			if !slices.Equal(got.Headings, tt.wantHeadings) {
+				t.Errorf("parseReadme() headings = %q, want %q", got.Headings, tt.wantHeadings)
+			}
+		})
+	}
+}
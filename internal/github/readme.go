@@ -0,0 +1,168 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// DefaultReadmeCandidates are the readme paths tried in order, relative to the repository root.
+// Root files are matched case-insensitively.
+var DefaultReadmeCandidates = []string{
+	"README.md",
+	"README.markdown",
+	"README.rst",
+	"README.adoc",
+	"README.asciidoc",
+	"README.org",
+	"README.txt",
+	"README",
+	"docs/README.md",
+	"docs/README.rst",
+	".github/README.md",
+}
+
+// readmeRateLimit is the rate limit state of the readmes query, decoded without the graphql tags of RateLimit.
+type readmeRateLimit struct {
+	Cost      int       `json:"cost"`
+	Limit     int       `json:"limit"`
+	Remaining int       `json:"remaining"`
+	Used      int       `json:"used"`
+	ResetAt   time.Time `json:"resetAt"`
+}
+
+// readmeBlob is the content of a readme candidate, null if the path does not exist.
+type readmeBlob struct {
+	Text string `json:"text"`
+}
+
+// resolveReadmes fetches the readme of the given repositories from their default branch, in a single query.
+// The readme is the first candidate found, converted to plain text according to its markup,
+// its section titles and, if enabled, its code blocks are kept apart.
+// A repository without readme gets an empty one, failing to query the readmes is an error.
+func (c *client) resolveReadmes(ctx context.Context, repos []*StarredRepository) error {
+	var q strings.Builder
+	paths := make(map[string][]string) // candidate paths by repository alias
+	for i, repo := range repos {
+		candidates := c.readmePaths(repo.Repository)
+		if len(candidates) == 0 {
+			continue
+		}
+
+		alias := fmt.Sprintf("r%d", i)
+		paths[alias] = candidates
+		fmt.Fprintf(&q, "%s: node(id: %s) { ... on Repository {", alias, quote(repo.Repository.ID))
+		for j, p := range candidates {
+			expr := repo.Repository.DefaultBranchRef.Name + ":" + p
+			fmt.Fprintf(&q, " c%d: object(expression: %s) { ... on Blob { text } }", j, quote(expr))
+		}
+
+		q.WriteString(" } } ")
+	}
+
+	if len(paths) == 0 {
+		return nil
+	}
+
+	var data []byte
+	err := c.retry(ctx, func() error {
+		var err error
+		data, err = c.c.ExecRaw(ctx, "query { "+q.String()+"rateLimit { cost limit remaining used resetAt } }", nil)
+		if err != nil && len(data) > 0 && isFieldError(err, func([]any) bool { return true }) {
+			return nil // some repositories could not be resolved
+		}
+
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to fetch readmes: %w", err)
+	}
+
+	var res map[string]json.RawMessage
+	if err := json.Unmarshal(data, &res); err != nil {
+		return fmt.Errorf("failed to decode readmes: %w", err)
+	}
+
+	// the rate limit is null when disabled on GitHub Enterprise Server
+	var rateLimit *readmeRateLimit
+	if err := json.Unmarshal(res["rateLimit"], &rateLimit); err != nil {
+		return fmt.Errorf("failed to decode rate limit: %w", err)
+	}
+
+	c.setRateLimit((*RateLimit)(rateLimit))
+
+	for i, repo := range repos {
+		alias := fmt.Sprintf("r%d", i)
+		if len(paths[alias]) == 0 {
+			continue
+		}
+
+		var blobs map[string]*readmeBlob // null if the repository could not be resolved
+		if err := json.Unmarshal(res[alias], &blobs); err != nil {
+			return fmt.Errorf("failed to decode readme of %s: %w", repo.Repository.NameWithOwner, err)
+		}
+
+		for j, p := range paths[alias] {
+			if blob := blobs[fmt.Sprintf("c%d", j)]; blob != nil {
+				content := parseReadme(p, blob.Text)
+				repo.Repository.Readme = content.Text
+				repo.Repository.ReadmeHeadings = content.Headings
+				if c.readmeCode {
+					repo.Repository.ReadmeCode = content.Code
+				}
+
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// readmePaths returns the candidate paths of the readme of the repository, in the configured order.
+// Candidates in an existing directory are resolved by the query, the list ends at the first candidate
+// existing at the root of the default branch since the ones after it cannot be the readme.
+func (c *client) readmePaths(repo *Repository) []string {
+	if repo.DefaultBranchRef == nil {
+		return nil // empty repository
+	}
+
+	files := make(map[string]string) // actual file name by lowercase name
+	dirs := make(map[string]string)
+	for _, entry := range repo.DefaultBranchRef.Target.Commit.Tree.Entries {
+		switch entry.Type {
+		case "blob":
+			files[strings.ToLower(entry.Name)] = entry.Name
+		case "tree":
+			dirs[strings.ToLower(entry.Name)] = entry.Name
+		}
+	}
+
+	paths := make([]string, 0)
+	for _, candidate := range c.readmeCandidates {
+		dir, file := path.Split(candidate)
+		if dir == "" {
+			if name, ok := files[strings.ToLower(file)]; ok {
+				return append(paths, name)
+			}
+
+			continue
+		}
+
+		top, rest, _ := strings.Cut(dir, "/")
+		if name, ok := dirs[strings.ToLower(top)]; ok {
+			paths = append(paths, path.Join(name, rest, file))
+		}
+	}
+
+	return paths
+}
+
+// quote returns s as a GraphQL string literal.
+func quote(s string) string {
+	b, _ := json.Marshal(s) // cannot fail on a string
+	return string(b)
+}
@@ -0,0 +1,176 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/hasura/go-graphql-client"
+
+	"github.com/SkYNewZ/gh-stars-search-engine/internal/slogx"
+)
+
+// maxRetryDelay caps the exponential backoff between retries.
+const maxRetryDelay = time.Minute
+
+// optionalFields are the query fields allowed to be resolved with an error, anywhere in the error path.
+// GitHub Enterprise Server answers rateLimit with an error when rate limiting is disabled on the instance,
+// and lists when star lists are disabled, the stars being indexed without lists.
+// A field missing from the schema of an older instance rejects the whole query instead:
+// the stars query needs GitHub Enterprise Server 3.0 or later, for stargazerCount and repositoryTopics.
+var optionalFields = map[string]struct{}{
+	"rateLimit": {},
+	"lists":     {},
+}
+
+// query executes the given query, retrying transient failures with an exponential backoff and jitter.
+// q must be a pointer, it is reset before each attempt so that a failed one leaves no partial data.
+func (c *client) query(ctx context.Context, q any, vars map[string]any) error {
+	return c.retry(ctx, func() error {
+		reflect.ValueOf(q).Elem().SetZero()
+		return c.c.Query(ctx, q, vars)
+	})
+}
+
+// retry calls fn until it succeeds, retrying transient failures with an exponential backoff and jitter.
+// A query rejected by the rate limit is retried once the rate limit is reset.
+func (c *client) retry(ctx context.Context, fn func() error) error {
+	var err error
+	for attempt := 0; ; attempt++ {
+		if err = fn(); err == nil || isPartial(err) {
+			return nil
+		}
+
+		rateLimited := isRateLimited(err)
+		if rateLimited && attempt >= c.maxRetries {
+			return fmt.Errorf("failed to query: %w: %w", ErrRateLimited, err)
+		}
+
+		if !rateLimited && (!isRetryable(err) || attempt >= c.maxRetries) {
+			return fmt.Errorf("failed to query: %w", err)
+		}
+
+		delay := backoff(c.retryDelay, attempt)
+		if rateLimited {
+			delay = c.untilReset(delay)
+			c.logger.With(slogx.Err(err)).Warn(fmt.Sprintf("rate limit reached, retrying in %s for its reset (%d/%d)", delay, attempt+1, c.maxRetries))
+		} else {
+			c.logger.With(slogx.Err(err)).Warn(fmt.Sprintf("query failed, retrying in %s (%d/%d)", delay, attempt+1, c.maxRetries))
+		}
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return fmt.Errorf("failed to query: %w", ctx.Err())
+		}
+	}
+}
+
+// untilReset returns the delay until the reset of the last recorded rate limit, or fallback if it is unknown or past.
+func (c *client) untilReset(fallback time.Duration) time.Duration {
+	if rateLimit := c.RateLimit(); rateLimit != nil {
+		if wait := time.Until(rateLimit.ResetAt); wait > 0 {
+			return wait
+		}
+	}
+
+	return fallback
+}
+
+// backoff returns the delay to wait before the given retry attempt.
+// It uses an exponential backoff with full jitter.
+func backoff(base time.Duration, attempt int) time.Duration {
+	delay := maxRetryDelay
+	if attempt < 32 && base<<attempt > 0 && base<<attempt < maxRetryDelay {
+		delay = base << attempt
+	}
+
+	return time.Duration(rand.Int63n(int64(delay) + 1)) //nolint:gosec // jitter does not need a secure random
+}
+
+// isPartial reports whether the given query error only concerns optional fields,
+// the rest of the response being decoded anyway.
+func isPartial(err error) bool {
+	return isFieldError(err, func(path []any) bool {
+		for _, elem := range path {
+			if field, ok := elem.(string); ok {
+				if _, ok := optionalFields[field]; ok {
+					return true
+				}
+			}
+		}
+
+		return false
+	})
+}
+
+// isFieldError reports whether the given query error only concerns fields whose path is accepted by the given function,
+// the rest of the response being available anyway.
+func isFieldError(err error, accept func(path []any) bool) bool {
+	var gqlErrs graphql.Errors
+	if !errors.As(err, &gqlErrs) {
+		return false
+	}
+
+	for _, e := range gqlErrs {
+		if len(e.Path) == 0 {
+			return false
+		}
+
+		if !accept(e.Path) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isRateLimited reports whether the given query error is the GraphQL RATE_LIMITED error,
+// returned when the query costs more points than the remaining ones.
+func isRateLimited(err error) bool {
+	var gqlErrs graphql.Errors
+	if !errors.As(err, &gqlErrs) {
+		return false
+	}
+
+	for _, e := range gqlErrs {
+		// the error type is not decoded by the GraphQL client, only its message tells it apart
+		if code, _ := e.Extensions["code"].(string); code == "RATE_LIMITED" || strings.HasPrefix(e.Message, "API rate limit") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isRetryable reports whether the given query error is transient.
+// Network failures, unexpected HTTP statuses and undecodable responses are retried,
+// invalid credentials, GraphQL errors and context cancellation are not.
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var gqlErrs graphql.Errors
+	if !errors.As(err, &gqlErrs) {
+		return true
+	}
+
+	for _, e := range gqlErrs {
+		switch code, _ := e.Extensions["code"].(string); code {
+		case graphql.ErrRequestError:
+			if strings.HasPrefix(e.Message, "401 ") {
+				return false // bad credentials
+			}
+		case graphql.ErrJsonDecode:
+		default:
+			return false // the query itself is rejected
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,236 @@
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hasura/go-graphql-client"
+)
+
+// loginQuery is a minimal query answered by the fake server of newStatusServer.
+type loginQuery struct {
+	Viewer struct {
+		Login string `graphql:"login"`
+	} `graphql:"viewer"`
+}
+
+// newStatusServer returns a fake GraphQL API answering the queries with the given HTTP statuses in turn,
+// the last one being repeated. It counts the received requests and calls onRequest, if any, on each of them.
+func newStatusServer(t *testing.T, statuses []int, requests *atomic.Int32, onRequest func()) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		n := int(requests.Add(1))
+		if onRequest != nil {
+			onRequest()
+		}
+
+		status := statuses[min(n, len(statuses))-1]
+		switch status {
+		case http.StatusOK:
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = io.WriteString(w, `{"data": {"viewer": {"login": "octocat"}}}`)
+		case http.StatusForbidden:
+			http.Error(w, `{"message": "You have exceeded a secondary rate limit."}`, status)
+		default:
+			http.Error(w, http.StatusText(status), status)
+		}
+	}))
+
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newRetryClient returns a client of the given fake server retrying failed queries maxRetries times.
+func newRetryClient(srv *httptest.Server, maxRetries int, retryDelay time.Duration) *client {
+	return &client{
+		c:          graphql.NewClient(srv.URL, srv.Client()),
+		logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+		maxRetries: maxRetries,
+		retryDelay: retryDelay,
+	}
+}
+
+func TestQueryRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantRequests int32
+		wantErr      bool
+	}{
+		{name: "server error", statuses: []int{http.StatusBadGateway, http.StatusOK}, wantRequests: 2},
+		{name: "secondary rate limit", statuses: []int{http.StatusForbidden, http.StatusOK}, wantRequests: 2},
+		{name: "bad credentials", statuses: []int{http.StatusUnauthorized}, wantRequests: 1, wantErr: true},
+		{name: "retries exhausted", statuses: []int{http.StatusInternalServerError}, wantRequests: 3, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			c := newRetryClient(newStatusServer(t, tt.statuses, &requests, nil), 2, time.Millisecond)
+
+			var q loginQuery
+			if err := c.query(context.Background(), &q, nil); (err != nil) != tt.wantErr {
+				t.Fatalf("query() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if !tt.wantErr && q.Viewer.Login != "octocat" {
+				t.Errorf("query() login = %q, want octocat", q.Viewer.Login)
+			}
+
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestQueryRetryCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the context is canceled once the failure is answered, while the client waits before retrying
+	var requests atomic.Int32
+	srv := newStatusServer(t, []int{http.StatusInternalServerError}, &requests, func() {
+		time.AfterFunc(50*time.Millisecond, cancel)
+	})
+	c := newRetryClient(srv, 5, time.Hour)
+
+	done := make(chan error, 1)
+	go func() {
+		var q loginQuery
+		done <- c.query(ctx, &q, nil)
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("query() error = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("query() did not return once the context was canceled")
+	}
+
+	if got := requests.Load(); got != 1 {
+		t.Errorf("requests = %d, want 1", got)
+	}
+}
+
+// rateLimitedPayload answers a query rejected by the rate limit.
+const rateLimitedPayload string = `{
+  "data": null,
+  "errors": [{"type": "RATE_LIMITED", "message": "API rate limit exceeded for user ID 1."}]
+}`
+
+func TestQueryRateLimited(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxRetries   int
+		wantRequests int32
+		wantErr      error
+	}{
+		{name: "retried after the reset", maxRetries: 1, wantRequests: 2},
+		{name: "retries exhausted", maxRetries: 0, wantRequests: 1, wantErr: ErrRateLimited},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				if requests.Add(1) == 1 {
+					_, _ = io.WriteString(w, rateLimitedPayload)
+					return
+				}
+
+				_, _ = io.WriteString(w, `{"data": {"viewer": {"login": "octocat"}}}`)
+			}))
+			t.Cleanup(srv.Close)
+
+			// the retry waits for the reset of the last recorded rate limit rather than the backoff delay
+			reset := 200 * time.Millisecond
+			c := newRetryClient(srv, tt.maxRetries, time.Hour)
+			c.setRateLimit(&RateLimit{Limit: 5000, ResetAt: time.Now().Add(reset)})
+
+			start := time.Now()
+			var q loginQuery
+			if err := c.query(context.Background(), &q, nil); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("query() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", got, tt.wantRequests)
+			}
+
+			if elapsed := time.Since(start); tt.wantErr == nil && (elapsed < reset/2 || elapsed > 10*time.Second) {
+				t.Errorf("query() took %s, want about %s", elapsed, reset)
+			}
+		})
+	}
+}
+
+func TestQueryResetsTarget(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if requests.Add(1) == 1 {
+			// partial data along with the error, decoded before the retry
+			_, _ = io.WriteString(w, `{
+  "data": {"viewer": {"login": "octocat", "bio": "stale"}},
+  "errors": [{"type": "RATE_LIMITED", "message": "API rate limit exceeded for user ID 1."}]
+}`)
+			return
+		}
+
+		_, _ = io.WriteString(w, `{"data": {"viewer": {"login": "octocat"}}}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	var q struct {
+		Viewer struct {
+			Login string  `graphql:"login"`
+			Bio   *string `graphql:"bio"`
+		} `graphql:"viewer"`
+	}
+
+	c := newRetryClient(srv, 1, time.Millisecond)
+	if err := c.query(context.Background(), &q, nil); err != nil {
+		t.Fatalf("query() error = %v", err)
+	}
+
+	if q.Viewer.Login != "octocat" || q.Viewer.Bio != nil {
+		t.Errorf("query() = %+v, want only the data of the last attempt", q.Viewer)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		base    time.Duration
+		attempt int
+		want    time.Duration // upper bound of the delay
+	}{
+		{base: time.Second, attempt: 0, want: time.Second},
+		{base: time.Second, attempt: 3, want: 8 * time.Second},
+		{base: time.Second, attempt: 6, want: maxRetryDelay},
+		{base: time.Second, attempt: 40, want: maxRetryDelay},
+		{base: time.Second, attempt: 100, want: maxRetryDelay},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s attempt %d", tt.base, tt.attempt), func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				if got := backoff(tt.base, tt.attempt); got < 0 || got > tt.want {
+					t.Fatalf("backoff() = %s, want between 0 and %s", got, tt.want)
+				}
+			}
+		})
+	}
+}
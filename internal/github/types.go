@@ -1,12 +1,15 @@
 package github
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 /*
 	query ($cursor: String) {
 	  viewer {
 	    login
-	    starredRepositories(first: 3, after: $cursor) {
+	    starredRepositories(first: 3, after: $cursor, orderBy: {field: STARRED_AT, direction: DESC}) {
 	      totalCount
 	      pageInfo {
 	        endCursor
@@ -15,20 +18,27 @@ import "time"
 	        hasPreviousPage
 	      }
 	      repositories: edges {
+	        cursor
 	        starredAt
 	        repository: node {
 	          id
 	          nameWithOwner
+	          owner {
+	            login
+	          }
 	          description
 	          url
-	          r1:object(expression: "HEAD:README.md") {
-	            ... on Blob {
-	              text
-	            }
-	          }
-	          r2:object(expression: "HEAD:readme.md") {
-	            ... on Blob {
-	              text
+	          defaultBranchRef {
+	            name
+	            target {
+	              ... on Commit {
+	                tree {
+	                  entries {
+	                    name
+	                    type
+	                  }
+	                }
+	              }
 	            }
 	          }
 	          primaryLanguage {
@@ -36,6 +46,25 @@ import "time"
 	            name
 	            color
 	          }
+	          repositoryTopics(first: 20) {
+	            nodes {
+	              topic {
+	                name
+	              }
+	            }
+	          }
+	          stargazerCount
+	          forkCount
+	          licenseInfo {
+	            key
+	            name
+	            spdxId
+	          }
+	          isArchived
+	          isFork
+	          homepageUrl
+	          pushedAt
+	          updatedAt
 	        }
 	      }
 	    }
@@ -51,18 +80,37 @@ import "time"
 */
 type query struct {
 	// Viewer is the currently authenticated user
-	Viewer struct {
-		// Login is the username of the user
-		Login string `graphql:"login" json:"login"`
+	Viewer stargazer `graphql:"viewer" json:"viewer"`
+
+	// RateLimit contains the rate limit information
+	RateLimit *RateLimit `graphql:"rateLimit" json:"rate_limit"`
+}
 
-		// StarredRepositories is the list of repositories starred by the user
-		StarredRepositories *StarredRepositories `graphql:"starredRepositories(first: $count, after: $cursor)" json:"starred_repositories"`
-	} `graphql:"viewer" json:"viewer"`
+// userQuery is the same as query for the user having the $login login.
+type userQuery struct {
+	// User is the user having the given login
+	User stargazer `graphql:"user(login: $login)" json:"user"`
 
 	// RateLimit contains the rate limit information
 	RateLimit *RateLimit `graphql:"rateLimit" json:"rate_limit"`
 }
 
+// viewerQuery fetches the login of the currently authenticated user.
+type viewerQuery struct {
+	Viewer struct {
+		Login string `graphql:"login"`
+	} `graphql:"viewer"`
+}
+
+// stargazer is a user and the repositories they starred.
+type stargazer struct {
+	// Login is the username of the user
+	Login string `graphql:"login" json:"login"`
+
+	// StarredRepositories is the list of repositories starred by the user, most recently starred first
+	StarredRepositories *StarredRepositories `graphql:"starredRepositories(first: $count, after: $cursor, orderBy: {field: STARRED_AT, direction: DESC})" json:"starred_repositories"`
+}
+
 // StarredRepositories is the list of repositories starred by the user.
 type StarredRepositories struct {
 	TotalCount   int                  `graphql:"totalCount" json:"total_count"`
@@ -89,6 +137,7 @@ type RateLimit struct {
 
 // StarredRepository is a repository starred by the user.
 type StarredRepository struct {
+	Cursor     string      `graphql:"cursor"    json:"-"` // position of the star in the listing
 	StarredAt  time.Time   `graphql:"starredAt" json:"starred_at"`
 	Repository *Repository `graphql:"node"      json:"repository"`
 }
@@ -100,24 +149,170 @@ type Repository struct {
 	Description   string `graphql:"description"   json:"description"`
 	URL           string `graphql:"url"           json:"url"`
 
-	R1     *readme `graphql:"r1:object(expression: \"HEAD:README.md\")" json:"-"`      // content of README.md
-	R2     *readme `graphql:"r2:object(expression: \"HEAD:readme.md\")" json:"-"`      // content of readme.md
-	Readme string  `graphql:"-"                                         json:"readme"` // computed field
+	Owner struct {
+		Login string `graphql:"login" json:"login"`
+	} `graphql:"owner" json:"owner"`
+
+	DefaultBranchRef *defaultBranchRef `graphql:"defaultBranchRef" json:"-"`                     // null for empty repositories
+	Readme           string            `graphql:"-"                json:"readme"`                // computed field, resolved among the root files
+	ReadmeHeadings   []string          `graphql:"-"                json:"readme_headings"`       // computed field, section titles of the readme
+	ReadmeCode       string            `graphql:"-"                json:"readme_code,omitempty"` // computed field, code blocks of the readme if enabled
 
 	PrimaryLanguage struct {
 		ID    string `graphql:"id"    json:"id"`
 		Name  string `graphql:"name"  json:"name"`
 		Color string `graphql:"color" json:"color"`
 	} `graphql:"primaryLanguage" json:"primary_language"`
+
+	RepositoryTopics struct {
+		Nodes []struct {
+			Topic struct {
+				Name string `graphql:"name" json:"-"`
+			} `graphql:"topic" json:"-"`
+		} `graphql:"nodes" json:"-"`
+	} `graphql:"repositoryTopics(first: 20)" json:"-"`
+	Topics []string `graphql:"-" json:"topics"` // computed field
+
+	StargazerCount int `graphql:"stargazerCount" json:"stargazer_count"`
+	ForkCount      int `graphql:"forkCount"      json:"fork_count"`
+
+	LicenseInfo struct {
+		Key    string `graphql:"key"    json:"key"`
+		Name   string `graphql:"name"   json:"name"`
+		SpdxID string `graphql:"spdxId" json:"spdx_id"`
+	} `graphql:"licenseInfo" json:"license"`
+
+	IsArchived  bool       `graphql:"isArchived"  json:"is_archived"`
+	IsFork      bool       `graphql:"isFork"      json:"is_fork"`
+	HomepageURL string     `graphql:"homepageUrl" json:"homepage_url"`
+	PushedAt    *time.Time `graphql:"pushedAt"    json:"pushed_at"` // null for empty repositories
+	UpdatedAt   time.Time  `graphql:"updatedAt"   json:"updated_at"`
+
+	StarredAt time.Time `graphql:"-" json:"starred_at"` // computed field, copied from the star
+	Lists     []string  `graphql:"-" json:"lists"`      // computed field, slugs of the star lists containing the repository
+
+	StarredBy      []string             `graphql:"-" json:"starred_by"`       // computed field, logins of the users who starred the repository
+	StarredByCount int                  `graphql:"-" json:"starred_by_count"` // computed field
+	StarredAtBy    map[string]time.Time `graphql:"-" json:"starred_at_by"`    // computed field, star date by login
+}
+
+/*
+	query ($count: Int!, $cursor: String!) {
+	  viewer {
+	    lists(first: $count, after: $cursor) {
+	      pageInfo {
+	        endCursor
+	        hasNextPage
+	      }
+	      nodes {
+	        id
+	        name
+	        slug
+	        items(first: $count) {
+	          pageInfo {
+	            endCursor
+	            hasNextPage
+	          }
+	          nodes {
+	            ... on Repository {
+	              id
+	            }
+	          }
+	        }
+	      }
+	    }
+	  }
+	}
+*/
+type listsQuery struct {
+	Viewer    listsOwner `graphql:"viewer"`
+	RateLimit *RateLimit `graphql:"rateLimit"`
+}
+
+// userListsQuery is the same as listsQuery for the user having the $login login.
+type userListsQuery struct {
+	User      listsOwner `graphql:"user(login: $login)"`
+	RateLimit *RateLimit `graphql:"rateLimit"`
+}
+
+type listsOwner struct {
+	Lists struct {
+		PageInfo *PageInfo  `graphql:"pageInfo"`
+		Nodes    []userList `graphql:"nodes"`
+	} `graphql:"lists(first: $count, after: $cursor)"`
+}
+
+// listItemsQuery fetches the next items of a list having more than one page of items.
+type listItemsQuery struct {
+	Node struct {
+		UserList struct {
+			Items listItems `graphql:"items(first: $count, after: $cursor)"`
+		} `graphql:"... on UserList"`
+	} `graphql:"node(id: $id)"`
+	RateLimit *RateLimit `graphql:"rateLimit"`
+}
+
+type userList struct {
+	ID    string    `graphql:"id"`
+	Name  string    `graphql:"name"`
+	Slug  string    `graphql:"slug"`
+	Items listItems `graphql:"items(first: $count)"`
+}
+
+type listItems struct {
+	PageInfo *PageInfo `graphql:"pageInfo"`
+	Nodes    []struct {
+		Repository struct {
+			ID string `graphql:"id"`
+		} `graphql:"... on Repository"`
+	} `graphql:"nodes"`
+}
+
+// List is a star list of the user.
+type List struct {
+	ID            string   `json:"id"`
+	Name          string   `json:"name"`
+	Slug          string   `json:"slug"`
+	RepositoryIDs []string `json:"repository_ids"`
+}
+
+// defaultBranchRef is the default branch of a repository and the entries of its root directory.
+type defaultBranchRef struct {
+	Name   string `graphql:"name"`
+	Target struct {
+		Commit struct {
+			Tree struct {
+				Entries []treeEntry `graphql:"entries"`
+			} `graphql:"tree"`
+		} `graphql:"... on Commit"`
+	} `graphql:"target"`
 }
 
-type readme struct {
-	Blob struct {
-		Text string `graphql:"text" json:"-"`
-	} `graphql:"... on Blob" json:"-"`
+// treeEntry is a file ("blob") or a directory ("tree") of a git tree.
+type treeEntry struct {
+	Name string `graphql:"name"`
+	Type string `graphql:"type"`
 }
 
+// embeddingReadmeLength is the number of readme characters the repository vector is computed from.
+const embeddingReadmeLength int = 2000
+
 // GetID returns the repository ID.
 func (r *Repository) GetID() string {
 	return r.ID
 }
+
+// EmbeddingText returns the text the repository vector is computed from:
+// its name, description, topics, readme titles and the beginning of its readme.
+func (r *Repository) EmbeddingText() string {
+	readme := []rune(r.Readme)
+	parts := []string{
+		r.NameWithOwner,
+		r.Description,
+		strings.Join(r.Topics, " "),
+		strings.Join(r.ReadmeHeadings, "\n"),
+		string(readme[:min(embeddingReadmeLength, len(readme))]),
+	}
+
+	return strings.Join(parts, "\n")
+}
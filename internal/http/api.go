@@ -0,0 +1,306 @@
+package http
+
+import (
+	"context"
+	_ "embed"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search"
+
+	"github.com/SkYNewZ/gh-stars-search-engine/internal/engine"
+)
+
+// openAPISpec is the OpenAPI document describing the versioned API.
+//
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+// repositoryFields are the stored fields returned as a Repository by the versioned API.
+var repositoryFields = []string{
+	"name_with_owner",
+	"owner.login",
+	"description",
+	"url",
+	"homepage_url",
+	"primary_language.name",
+	"primary_language.color",
+	"topics",
+	"lists",
+	"license.spdx_id",
+	"stargazer_count",
+	"fork_count",
+	"is_archived",
+	"is_fork",
+	"pushed_at",
+	"updated_at",
+	"starred_at",
+	"starred_by",
+}
+
+// SearchResponse is the response of the /api/v1/search endpoint.
+type SearchResponse struct {
+	Total      uint64            `json:"total"`
+	MaxScore   float64           `json:"max_score"`
+	TookMs     float64           `json:"took_ms"`
+	Hits       []*SearchHit      `json:"hits"`
+	Facets     map[string]*Facet `json:"facets,omitempty"`
+	Pagination *Pagination       `json:"pagination"`
+}
+
+// SearchHit is a repository matching the search query.
+type SearchHit struct {
+	ID         string              `json:"id"`
+	Score      float64             `json:"score"`
+	Repository *Repository         `json:"repository"`
+	Fragments  map[string][]string `json:"fragments,omitempty"`
+}
+
+// Repository is an indexed starred repository.
+type Repository struct {
+	ID             string     `json:"id"`
+	NameWithOwner  string     `json:"name_with_owner"`
+	Owner          string     `json:"owner"`
+	Description    string     `json:"description"`
+	URL            string     `json:"url"`
+	HomepageURL    string     `json:"homepage_url,omitempty"`
+	Language       *Language  `json:"language,omitempty"`
+	Topics         []string   `json:"topics"`
+	Lists          []string   `json:"lists"`
+	License        string     `json:"license,omitempty"`
+	StargazerCount int        `json:"stargazer_count"`
+	ForkCount      int        `json:"fork_count"`
+	IsArchived     bool       `json:"is_archived"`
+	IsFork         bool       `json:"is_fork"`
+	PushedAt       *time.Time `json:"pushed_at,omitempty"`
+	UpdatedAt      *time.Time `json:"updated_at,omitempty"`
+	StarredAt      *time.Time `json:"starred_at,omitempty"`
+	StarredBy      []string   `json:"starred_by"`
+}
+
+// Language is the primary language of a repository.
+type Language struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// Facet is the result of a facet request.
+type Facet struct {
+	Field   string         `json:"field"`
+	Total   int            `json:"total"`
+	Missing int            `json:"missing"`
+	Other   int            `json:"other"`
+	Buckets []*FacetBucket `json:"buckets"`
+}
+
+// FacetBucket is a term or a range of a facet with its number of matching repositories.
+type FacetBucket struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// Pagination contains the links to the surrounding pages, nil when there is no such page.
+type Pagination struct {
+	From     int     `json:"from"`
+	Size     int     `json:"size"`
+	Next     *string `json:"next"`
+	Previous *string `json:"previous"`
+}
+
+func (s *server) searchV1Handler(w http.ResponseWriter, r *http.Request) {
+	params, err := s.parseSearchParams(r)
+	if err != nil {
+		s.responseErrorAsJSON(w, r, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.searchTimeout)
+	defer cancel()
+
+	res, err := s.search.Search(ctx, params.query, append(params.opts, engine.WithSearchFields(repositoryFields...))...)
+	if err != nil {
+		s.responseErrorAsJSON(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s.responseAsJSON(w, r, http.StatusOK, newSearchResponse(r, params, res))
+}
+
+func (s *server) openAPIHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	_, _ = w.Write(openAPISpec)
+}
+
+// newSearchResponse converts the search result into a SearchResponse.
+func newSearchResponse(r *http.Request, params *searchParams, res *bleve.SearchResult) *SearchResponse {
+	resp := &SearchResponse{
+		Total:    res.Total,
+		MaxScore: res.MaxScore,
+		TookMs:   float64(res.Took) / float64(time.Millisecond),
+		Hits:     make([]*SearchHit, 0, len(res.Hits)),
+		Pagination: &Pagination{
+			From: params.from,
+			Size: params.size,
+		},
+	}
+
+	for _, hit := range res.Hits {
+		resp.Hits = append(resp.Hits, &SearchHit{
+			ID:         hit.ID,
+			Score:      hit.Score,
+			Repository: newRepository(hit),
+			Fragments:  nonEmptyFragments(hit.Fragments),
+		})
+	}
+
+	if len(res.Facets) > 0 {
+		resp.Facets = make(map[string]*Facet, len(res.Facets))
+		for name, facet := range res.Facets {
+			resp.Facets[name] = newFacet(facet)
+		}
+	}
+
+	if next := params.from + params.size; uint64(next) < res.Total {
+		resp.Pagination.Next = pageURL(r, next)
+	}
+
+	if params.from > 0 {
+		resp.Pagination.Previous = pageURL(r, max(params.from-params.size, 0))
+	}
+
+	return resp
+}
+
+// newRepository reads the stored fields of the hit into a Repository.
+func newRepository(hit *search.DocumentMatch) *Repository {
+	repo := &Repository{
+		ID:             hit.ID,
+		NameWithOwner:  fieldString(hit.Fields, "name_with_owner"),
+		Owner:          fieldString(hit.Fields, "owner.login"),
+		Description:    fieldString(hit.Fields, "description"),
+		URL:            fieldString(hit.Fields, "url"),
+		HomepageURL:    fieldString(hit.Fields, "homepage_url"),
+		Topics:         fieldStrings(hit.Fields, "topics"),
+		Lists:          fieldStrings(hit.Fields, "lists"),
+		License:        fieldString(hit.Fields, "license.spdx_id"),
+		StargazerCount: fieldInt(hit.Fields, "stargazer_count"),
+		ForkCount:      fieldInt(hit.Fields, "fork_count"),
+		IsArchived:     fieldBool(hit.Fields, "is_archived"),
+		IsFork:         fieldBool(hit.Fields, "is_fork"),
+		PushedAt:       fieldTime(hit.Fields, "pushed_at"),
+		UpdatedAt:      fieldTime(hit.Fields, "updated_at"),
+		StarredAt:      fieldTime(hit.Fields, "starred_at"),
+		StarredBy:      fieldStrings(hit.Fields, "starred_by"),
+	}
+
+	if name := fieldString(hit.Fields, "primary_language.name"); name != "" {
+		repo.Language = &Language{
+			Name:  name,
+			Color: fieldString(hit.Fields, "primary_language.color"),
+		}
+	}
+
+	return repo
+}
+
+// newFacet flattens the terms or ranges of the facet result into buckets.
+func newFacet(facet *search.FacetResult) *Facet {
+	f := &Facet{
+		Field:   facet.Field,
+		Total:   facet.Total,
+		Missing: facet.Missing,
+		Other:   facet.Other,
+		Buckets: make([]*FacetBucket, 0),
+	}
+
+	if facet.Terms != nil {
+		for _, t := range facet.Terms.Terms() {
+			f.Buckets = append(f.Buckets, &FacetBucket{Name: t.Term, Count: t.Count})
+		}
+	}
+
+	for _, nr := range facet.NumericRanges {
+		f.Buckets = append(f.Buckets, &FacetBucket{Name: nr.Name, Count: nr.Count})
+	}
+
+	for _, dr := range facet.DateRanges {
+		f.Buckets = append(f.Buckets, &FacetBucket{Name: dr.Name, Count: dr.Count})
+	}
+
+	return f
+}
+
+// nonEmptyFragments drops the fields having no fragment, such as an empty readme.
+func nonEmptyFragments(fragments search.FieldFragmentMap) map[string][]string {
+	out := make(map[string][]string, len(fragments))
+	for field, frags := range fragments {
+		for _, frag := range frags {
+			if frag != "" {
+				out[field] = append(out[field], frag)
+			}
+		}
+	}
+
+	return out
+}
+
+// pageURL returns the URL of the request with the from query param set to the given value.
+func pageURL(r *http.Request, from int) *string {
+	u := *r.URL
+	query := u.Query()
+	query.Set("from", strconv.Itoa(from))
+	u.RawQuery = query.Encode()
+
+	link := u.RequestURI()
+	return &link
+}
+
+func fieldString(fields map[string]any, key string) string {
+	v, _ := fields[key].(string)
+	return v
+}
+
+// fieldStrings reads an array field, bleve returns a single value array as a plain value.
+func fieldStrings(fields map[string]any, key string) []string {
+	switch v := fields[key].(type) {
+	case string:
+		return []string{v}
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, vv := range v {
+			if s, ok := vv.(string); ok {
+				values = append(values, s)
+			}
+		}
+
+		return values
+	default:
+		return []string{}
+	}
+}
+
+func fieldInt(fields map[string]any, key string) int {
+	v, _ := fields[key].(float64)
+	return int(v)
+}
+
+func fieldBool(fields map[string]any, key string) bool {
+	v, _ := fields[key].(bool)
+	return v
+}
+
+func fieldTime(fields map[string]any, key string) *time.Time {
+	v, ok := fields[key].(string)
+	if !ok {
+		return nil
+	}
+
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil
+	}
+
+	return &t
+}
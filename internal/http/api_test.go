@@ -0,0 +1,435 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search"
+	"github.com/blevesearch/bleve/v2/search/query"
+
+	"github.com/SkYNewZ/gh-stars-search-engine/internal/engine"
+	"github.com/SkYNewZ/gh-stars-search-engine/internal/github"
+	"github.com/SkYNewZ/gh-stars-search-engine/internal/indexer"
+)
+
+// fakeEngine answers the searches with canned results.
+type fakeEngine struct {
+	engine.Engine // unused methods panic
+
+	result      *bleve.SearchResult
+	suggestions []*engine.Suggestion
+	stats       *engine.Stats
+	rebuilding  bool
+	err         error
+
+	requests []*bleve.SearchRequest // requests built by the options of the searches
+}
+
+func (e *fakeEngine) Search(_ context.Context, q query.Query, opts ...engine.SearchOption) (*bleve.SearchResult, error) {
+	return e.search(q, opts...)
+}
+
+func (e *fakeEngine) Similar(_ context.Context, id string, opts ...engine.SearchOption) (*bleve.SearchResult, error) {
+	return e.search(bleve.NewDocIDQuery([]string{id}), opts...)
+}
+
+func (e *fakeEngine) Suggest(context.Context, string, int, ...string) ([]*engine.Suggestion, error) {
+	return e.suggestions, e.err
+}
+
+func (e *fakeEngine) Stats(context.Context) (*engine.Stats, error) {
+	return e.stats, e.err
+}
+
+func (e *fakeEngine) DocCount() (uint64, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+
+	return e.stats.Documents, nil
+}
+
+func (e *fakeEngine) Rebuilding() bool {
+	return e.rebuilding
+}
+
+// search records the request built by the options and returns the canned result.
+func (e *fakeEngine) search(q query.Query, opts ...engine.SearchOption) (*bleve.SearchResult, error) {
+	request := bleve.NewSearchRequest(q)
+	for _, opt := range opts {
+		opt(request)
+	}
+
+	e.requests = append(e.requests, request)
+	return e.result, e.err
+}
+
+// fakeIndexer reports a canned sync status and users.
+type fakeIndexer struct {
+	indexer.Indexer // unused methods panic
+
+	status *indexer.Status
+	users  []string
+}
+
+func (i *fakeIndexer) Status(context.Context) (*indexer.Status, error) {
+	return i.status, nil
+}
+
+func (i *fakeIndexer) Users() []string {
+	return i.users
+}
+
+// newTestResult returns a search result of two repositories out of total.
+func newTestResult(total uint64) *bleve.SearchResult {
+	return &bleve.SearchResult{
+		Total:    total,
+		MaxScore: 1.5,
+		Took:     3 * time.Millisecond,
+		Hits: search.DocumentMatchCollection{
+			{
+				ID:    "R_1",
+				Score: 1.5,
+				Fields: map[string]any{
+					"name_with_owner":        "blevesearch/bleve",
+					"owner.login":            "blevesearch",
+					"description":            "A modern text indexing library for go",
+					"url":                    "https://github.com/blevesearch/bleve",
+					"primary_language.name":  "Go",
+					"primary_language.color": "#00ADD8",
+					"topics":                 []any{"search", "go"},
+					"license.spdx_id":        "Apache-2.0",
+					"stargazer_count":        10000.0,
+					"fork_count":             700.0,
+					"is_archived":            false,
+					"is_fork":                false,
+					"pushed_at":              "2024-05-01T10:00:00Z",
+					"updated_at":             "2024-05-01T10:00:00Z",
+					"starred_at":             "2024-04-01T10:00:00Z",
+				},
+				Fragments: search.FieldFragmentMap{
+					"description": {"A modern <mark>text</mark> indexing library for go"},
+					"readme":      {""},
+				},
+			},
+			{
+				ID:     "R_2",
+				Score:  0.5,
+				Fields: map[string]any{"name_with_owner": "empty/repository"},
+			},
+		},
+		Facets: search.FacetResults{
+			"language": &search.FacetResult{
+				Field: "primary_language.name",
+				Total: 2,
+				Terms: func() *search.TermFacets {
+					terms := &search.TermFacets{}
+					terms.Add(&search.TermFacet{Term: "Go", Count: 1})
+					return terms
+				}(),
+			},
+		},
+	}
+}
+
+// serve sends a GET request for the given URL to a server of the given fakes and returns the response.
+func serve(t *testing.T, e *fakeEngine, i *fakeIndexer, url string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	srv := NewServer(logger, e, i, time.Minute).(*server)
+
+	rec := httptest.NewRecorder()
+	srv.httpServer.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, url, nil))
+	if got := rec.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", got)
+	}
+
+	return rec
+}
+
+func TestSearchV1Handler(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		err      error
+		wantCode int
+		wantBody string
+	}{
+		{
+			name:     "search",
+			url:      "/api/v1/search?q=text&size=2&from=2&facets=language&highlight=html&archived=false",
+			wantCode: http.StatusOK,
+			wantBody: `"next":"/api/v1/search?archived=false\u0026facets=language\u0026from=4`,
+		},
+		{name: "missing query", url: "/api/v1/search", wantCode: http.StatusBadRequest, wantBody: "missing q query param"},
+		{name: "invalid mode", url: "/api/v1/search?q=text&mode=regexp", wantCode: http.StatusBadRequest, wantBody: "invalid search mode"},
+		{name: "invalid archived", url: "/api/v1/search?q=text&archived=maybe", wantCode: http.StatusBadRequest, wantBody: `invalid archived \"maybe\"`},
+		{name: "invalid sort", url: "/api/v1/search?q=text&sort=name", wantCode: http.StatusBadRequest, wantBody: `invalid sort key \"name\"`},
+		{name: "invalid facet", url: "/api/v1/search?q=text&facets=color", wantCode: http.StatusBadRequest, wantBody: "invalid facet"},
+		{name: "search failure", url: "/api/v1/search?q=text", err: errors.New("index closed"), wantCode: http.StatusInternalServerError, wantBody: "index closed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, &fakeEngine{result: newTestResult(10), err: tt.err}, nil, tt.url)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", rec.Body, tt.wantBody)
+			}
+
+			decodeResponse(t, "/search", rec, new(SearchResponse))
+		})
+	}
+}
+
+func TestSearchV1HandlerRequest(t *testing.T) {
+	e := &fakeEngine{result: newTestResult(2)}
+	serve(t, e, &fakeIndexer{users: []string{"octocat"}}, "/api/v1/search?q=text&size=5&from=10&user=OctoCat&fork=true")
+
+	if len(e.requests) != 1 {
+		t.Fatalf("searches = %d, want 1", len(e.requests))
+	}
+
+	request := e.requests[0]
+	if request.From != 10 || request.Size != 5 {
+		t.Errorf("search from %d size %d, want from 10 size 5", request.From, request.Size)
+	}
+
+	if len(request.Fields) != len(repositoryFields) {
+		t.Errorf("search fields = %v, want %v", request.Fields, repositoryFields)
+	}
+
+	// the user and fork filters are conjunctions around the text query, the login is lowercased as indexed
+	filters, err := json.Marshal(request.Query)
+	if err != nil {
+		t.Fatalf("failed to encode search query: %v", err)
+	}
+
+	for _, want := range []string{`{"term":"octocat","field":"starred_by"}`, `{"bool":true,"field":"is_fork"}`} {
+		if !strings.Contains(string(filters), want) {
+			t.Errorf("search query = %s, want it to contain %s", filters, want)
+		}
+	}
+}
+
+func TestSearchV1HandlerSort(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		want     search.SortOrder
+		wantCode int
+	}{
+		{
+			name:     "relevance",
+			url:      "/api/v1/search?q=text&sort=_score",
+			want:     search.SortOrder{&search.SortScore{Desc: true}},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "stars descending",
+			url:      "/api/v1/search?q=text&sort=-stargazers,_score",
+			want:     search.SortOrder{&search.SortField{Field: "stargazer_count", Desc: true}, &search.SortScore{Desc: true}},
+			wantCode: http.StatusOK,
+		},
+		{
+			name:     "user star date",
+			url:      "/api/v1/search?q=text&sort=starred_at&user=OctoCat",
+			want:     search.SortOrder{&search.SortField{Field: "starred_at_by.octocat"}},
+			wantCode: http.StatusOK,
+		},
+		{name: "least relevant", url: "/api/v1/search?q=text&sort=-_score", wantCode: http.StatusBadRequest},
+		{name: "unknown user", url: "/api/v1/search?q=text&sort=starred_at&user=hubot", wantCode: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &fakeEngine{result: newTestResult(2)}
+			rec := serve(t, e, &fakeIndexer{users: []string{"octocat"}}, tt.url)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			if len(e.requests) != 1 {
+				t.Fatalf("searches = %d, want 1", len(e.requests))
+			}
+
+			if got := e.requests[0].Sort; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("search sort = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSuggestHandler(t *testing.T) {
+	suggestions := []*engine.Suggestion{
+		{Term: "bleve", Field: "name_with_owner", Count: 2},
+		{Term: "blevesearch", Field: "owner.words", Count: 1},
+		{Term: "go", Field: "primary_language.name", Count: 10},
+	}
+
+	tests := []struct {
+		name     string
+		url      string
+		err      error
+		wantCode int
+		wantBody string
+	}{
+		{name: "suggest", url: "/api/suggest?prefix=ble", wantCode: http.StatusOK, wantBody: `{"text":"blevesearch","type":"owner","count":1}`},
+		{name: "missing prefix", url: "/api/suggest", wantCode: http.StatusBadRequest, wantBody: "missing prefix query param"},
+		{name: "suggest failure", url: "/api/suggest?prefix=ble", err: errors.New("index closed"), wantCode: http.StatusInternalServerError, wantBody: "index closed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, &fakeEngine{suggestions: suggestions, err: tt.err}, nil, tt.url)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", rec.Body, tt.wantBody)
+			}
+
+			decodeResponse(t, "/suggest", rec, new(SuggestResponse))
+		})
+	}
+}
+
+func TestSimilarHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		err      error
+		wantCode int
+		wantBody string
+	}{
+		{name: "similar", url: "/api/repos/R_3/similar?size=2", wantCode: http.StatusOK, wantBody: `"id":"R_1"`},
+		{name: "not indexed", url: "/api/repos/R_3/similar", err: fmt.Errorf("%w: R_3", engine.ErrNotFound), wantCode: http.StatusNotFound, wantBody: "document not found"},
+		{name: "invalid path", url: "/api/repos/R_3/R_4/similar", wantCode: http.StatusNotFound, wantBody: "not found"},
+		{name: "similar failure", url: "/api/repos/R_3/similar", err: errors.New("index closed"), wantCode: http.StatusInternalServerError, wantBody: "index closed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, &fakeEngine{result: newTestResult(3), err: tt.err}, nil, tt.url)
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", rec.Body, tt.wantBody)
+			}
+
+			decodeResponse(t, "/repos/{id}/similar", rec, new(SearchResponse))
+		})
+	}
+}
+
+func TestStatsHandler(t *testing.T) {
+	lastSyncAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		status   *indexer.Status
+		err      error
+		wantCode int
+		wantBody string
+	}{
+		{
+			name: "synced",
+			status: &indexer.Status{
+				LastSyncAt: &lastSyncAt,
+				RateLimits: map[string]*github.RateLimit{
+					"octocat": {Cost: 1, Limit: 5000, Remaining: 4999, Used: 1, ResetAt: lastSyncAt.Add(time.Hour)},
+				},
+			},
+			wantCode: http.StatusOK,
+			wantBody: `"languages":[{"name":"Go","count":1}]`,
+		},
+		{
+			name:     "never synced",
+			status:   &indexer.Status{Syncing: true, RateLimits: map[string]*github.RateLimit{"octocat": nil}},
+			wantCode: http.StatusOK,
+			wantBody: `"last_sync_at":null`,
+		},
+		{name: "stats failure", status: &indexer.Status{}, err: errors.New("index closed"), wantCode: http.StatusInternalServerError, wantBody: "index closed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &fakeEngine{
+				result: newTestResult(2),
+				stats:  &engine.Stats{Documents: 2, DiskSize: 4096, Backend: engine.BackendScorch},
+				err:    tt.err,
+			}
+
+			rec := serve(t, e, &fakeIndexer{status: tt.status}, "/api/stats")
+			if rec.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+
+			if !strings.Contains(rec.Body.String(), tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", rec.Body, tt.wantBody)
+			}
+
+			decodeResponse(t, "/stats", rec, new(StatsResponse))
+		})
+	}
+}
+
+func TestReadyHandler(t *testing.T) {
+	lastSyncAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		status     *indexer.Status
+		rebuilding bool
+		err        error
+		wantCode   int
+	}{
+		{name: "synced", status: &indexer.Status{LastSyncAt: &lastSyncAt}, wantCode: http.StatusOK},
+		{name: "never synced", status: &indexer.Status{Syncing: true}, wantCode: http.StatusServiceUnavailable},
+		{name: "rebuilding", status: &indexer.Status{Syncing: true}, rebuilding: true, wantCode: http.StatusOK},
+		{name: "index failure", status: &indexer.Status{LastSyncAt: &lastSyncAt}, err: errors.New("index closed"), wantCode: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// documents without a sync, such as a partially synced index, are not enough
+			e := &fakeEngine{stats: &engine.Stats{Documents: 2}, rebuilding: tt.rebuilding, err: tt.err}
+
+			rec := serve(t, e, &fakeIndexer{status: tt.status}, "/health/ready")
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.wantCode, rec.Body)
+			}
+
+			var res ReadinessResponse
+			if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+
+			if res.Ready != (tt.wantCode == http.StatusOK) {
+				t.Errorf("ready = %t, want %t", res.Ready, tt.wantCode == http.StatusOK)
+			}
+		})
+	}
+}
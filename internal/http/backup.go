@@ -0,0 +1,71 @@
+package http
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SkYNewZ/gh-stars-search-engine/internal/slogx"
+)
+
+// backupHandler streams a gzip compressed tar archive of the index, restorable with the restore command.
+// Requests must be authorized by the backup token, one backup runs at a time.
+func (s *server) backupHandler(w http.ResponseWriter, r *http.Request) {
+	if s.backupToken == "" {
+		s.responseErrorAsJSON(w, r, http.StatusNotFound, "backup endpoint is disabled")
+		return
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.backupToken)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		s.responseErrorAsJSON(w, r, http.StatusUnauthorized, "invalid backup token")
+		return
+	}
+
+	if !s.backupMu.TryLock() {
+		s.responseErrorAsJSON(w, r, http.StatusConflict, "backup already in progress")
+		return
+	}
+	defer s.backupMu.Unlock()
+
+	// the archive may take longer to send than the server write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		s.logger.With(slogx.Err(err)).Warn("failed to disable write deadline")
+	}
+
+	filename := "ghs-" + time.Now().UTC().Format("20060102T150405Z") + ".tar.gz"
+	bw := &backupWriter{ResponseWriter: w, filename: filename}
+	err := s.search.Backup(r.Context(), bw)
+	if err == nil {
+		return
+	}
+
+	if !bw.started {
+		s.responseErrorAsJSON(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// the archive is truncated, the error can only be logged
+	s.logger.With(slogx.Err(err)).Error("failed to write backup")
+}
+
+// backupWriter sends the archive headers on the first write, once the snapshot succeeded.
+type backupWriter struct {
+	http.ResponseWriter
+
+	filename string
+	started  bool
+}
+
+func (w *backupWriter) Write(p []byte) (int, error) {
+	if !w.started {
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+w.filename+`"`)
+		w.WriteHeader(http.StatusOK)
+		w.started = true
+	}
+
+	return w.ResponseWriter.Write(p)
+}
@@ -0,0 +1,81 @@
+package http
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/SkYNewZ/gh-stars-search-engine/internal/engine"
+)
+
+// defaultFacetSize is the number of terms returned by the term facets.
+const defaultFacetSize int = 10
+
+// facets maps the allowed facet names to their definition.
+var facets = map[string]func(now time.Time) engine.SearchOption{
+	"language": termFacet("language", "primary_language.name"),
+	"topic":    termFacet("topic", "topics"),
+	"owner":    termFacet("owner", "owner.login"),
+	"license":  termFacet("license", "license.spdx_id"),
+	"list":     termFacet("list", "lists"),
+	"user":     termFacet("user", "starred_by"),
+	"teammates": func(time.Time) engine.SearchOption {
+		return engine.WithSearchNumericRangeFacet(
+			"teammates",
+			"starred_by_count",
+			engine.NumericRange{Name: "1", Min: ptr(1.0), Max: ptr(2.0)},
+			engine.NumericRange{Name: "2", Min: ptr(2.0), Max: ptr(3.0)},
+			engine.NumericRange{Name: "3+", Min: ptr(3.0)},
+		)
+	},
+	"stars": func(time.Time) engine.SearchOption {
+		return engine.WithSearchNumericRangeFacet(
+			"stars",
+			"stargazer_count",
+			engine.NumericRange{Name: "< 10", Max: ptr(10.0)},
+			engine.NumericRange{Name: "10 - 100", Min: ptr(10.0), Max: ptr(100.0)},
+			engine.NumericRange{Name: "100 - 1k", Min: ptr(100.0), Max: ptr(1000.0)},
+			engine.NumericRange{Name: "1k - 10k", Min: ptr(1000.0), Max: ptr(10000.0)},
+			engine.NumericRange{Name: ">= 10k", Min: ptr(10000.0)},
+		)
+	},
+	"starred_at": func(now time.Time) engine.SearchOption {
+		return engine.WithSearchDateRangeFacet(
+			"starred_at",
+			"starred_at",
+			engine.DateRange{Name: "last week", Start: now.AddDate(0, 0, -7)},
+			engine.DateRange{Name: "last month", Start: now.AddDate(0, -1, 0)},
+			engine.DateRange{Name: "last year", Start: now.AddDate(-1, 0, 0)},
+			engine.DateRange{Name: "older", End: now.AddDate(-1, 0, 0)},
+		)
+	},
+}
+
+// termFacet returns a term facet definition on the given field.
+func termFacet(name, field string) func(time.Time) engine.SearchOption {
+	return func(time.Time) engine.SearchOption {
+		return engine.WithSearchTermFacet(name, field, defaultFacetSize)
+	}
+}
+
+// parseFacetsParam parses the comma separated facet names v into search options.
+// Returns an error if a facet is not allowed.
+func parseFacetsParam(v string) ([]engine.SearchOption, error) {
+	now := time.Now()
+	names := strings.Split(v, ",")
+	opts := make([]engine.SearchOption, 0, len(names))
+	for _, name := range names {
+		facet, ok := facets[name]
+		if !ok {
+			return nil, fmt.Errorf("invalid facet %q", name)
+		}
+
+		opts = append(opts, facet(now))
+	}
+
+	return opts, nil
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
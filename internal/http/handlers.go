@@ -3,11 +3,16 @@ package http
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io/fs"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 
+	"github.com/blevesearch/bleve/v2/search/query"
+
 	"github.com/SkYNewZ/gh-stars-search-engine/internal/engine"
 	"github.com/SkYNewZ/gh-stars-search-engine/internal/slogx"
 	"github.com/SkYNewZ/gh-stars-search-engine/ui"
@@ -15,11 +20,37 @@ import (
 
 const defaultPageSize int = 10
 
+// highlightStyles are the allowed highlight styles, "none" disables highlighting.
+var highlightStyles = map[string]bool{
+	"html": true,
+	"ansi": true,
+	"none": true,
+}
+
+// highlightFields are the fields fragments are generated for.
+var highlightFields = []string{
+	"name_with_owner",
+	"description",
+	"topics",
+	"readme",
+}
+
+// sortKeys maps the allowed sort keys to the indexed fields.
+// The relevance only sorts the most relevant first, bleve sorts "_score" in ascending order.
+var sortKeys = map[string]string{
+	"_score":     "-_score",
+	"starred_at": "starred_at",
+	"stargazers": "stargazer_count",
+	"forks":      "fork_count",
+	"pushed_at":  "pushed_at",
+	"updated_at": "updated_at",
+	"teammates":  "starred_by_count",
+}
+
 func (s *server) searchHandler(w http.ResponseWriter, r *http.Request) {
-	// read q query param
-	q := r.URL.Query().Get("q")
-	if q == "" {
-		s.responseErrorAsJSON(w, r, http.StatusBadRequest, "missing q query param")
+	params, err := s.parseSearchParams(r)
+	if err != nil {
+		s.responseErrorAsJSON(w, r, http.StatusBadRequest, err.Error())
 		return
 	}
 
@@ -31,25 +62,22 @@ func (s *server) searchHandler(w http.ResponseWriter, r *http.Request) {
 		"primary_language.color",
 	}
 
-	// read fields query param
+	// read fields query param, the readme is only stored for highlighting
 	if additionalFields := r.URL.Query().Get("fields"); additionalFields != "" {
-		searchResponseFields = append(searchResponseFields, strings.Split(additionalFields, ",")...)
-	}
+		for _, field := range strings.Split(additionalFields, ",") {
+			if field == "readme" || field == "*" {
+				s.responseErrorAsJSON(w, r, http.StatusBadRequest, fmt.Sprintf("field %q cannot be returned", field))
+				return
+			}
 
-	// pagination
-	pageSize := parseQueryParamPositive(r.URL.Query().Get("size"), defaultPageSize)
-	from := parseQueryParamPositive(r.URL.Query().Get("from"), 0)
+			searchResponseFields = append(searchResponseFields, field)
+		}
+	}
 
 	ctx, cancel := context.WithTimeout(r.Context(), s.searchTimeout)
 	defer cancel()
 
-	res, err := s.search.Search(
-		ctx,
-		q,
-		engine.WithSearchFrom(from),
-		engine.WithSearchSize(pageSize),
-		engine.WithSearchFields(searchResponseFields...),
-	)
+	res, err := s.search.Search(ctx, params.query, append(params.opts, engine.WithSearchFields(searchResponseFields...))...)
 	if err != nil {
 		s.responseErrorAsJSON(w, r, http.StatusInternalServerError, err.Error())
 		return
@@ -58,10 +86,6 @@ func (s *server) searchHandler(w http.ResponseWriter, r *http.Request) {
 	s.responseAsJSON(w, r, http.StatusOK, res)
 }
 
-func (s *server) healthHandler(w http.ResponseWriter, _ *http.Request) {
-	_, _ = w.Write([]byte("OK"))
-}
-
 func (s *server) uiHandler(w http.ResponseWriter, r *http.Request) {
 	_ui, err := fs.Sub(ui.Dist, "dist")
 	if err != nil {
@@ -107,3 +131,150 @@ func parseQueryParamPositive(v string, def int) int {
 
 	return i
 }
+
+// searchParams are the search query params shared by the search endpoints.
+type searchParams struct {
+	query query.Query
+	from  int
+	size  int
+	opts  []engine.SearchOption
+}
+
+// parseSearchParams parses the search query params of r.
+// Returns an error if a param is missing or invalid.
+func (s *server) parseSearchParams(r *http.Request) (*searchParams, error) {
+	// read q query param
+	q := r.URL.Query().Get("q")
+	if q == "" {
+		return nil, errors.New("missing q query param")
+	}
+
+	// search mode, default is the query string syntax
+	queryOpts := make([]engine.QueryOption, 0)
+	if mode := r.URL.Query().Get("mode"); mode != "" {
+		queryOpts = append(queryOpts, engine.WithQueryMode(engine.SearchMode(mode)))
+	}
+
+	if v := r.URL.Query().Get("fuzziness"); v != "" {
+		fuzziness, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fuzziness %q", v)
+		}
+
+		queryOpts = append(queryOpts, engine.WithQueryFuzziness(fuzziness))
+	}
+
+	if v := r.URL.Query().Get("vector_weight"); v != "" {
+		weight, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vector weight %q", v)
+		}
+
+		queryOpts = append(queryOpts, engine.WithQueryVectorWeight(weight))
+	}
+
+	searchQuery, err := engine.NewQuery(q, queryOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// pagination
+	pageSize := parseQueryParamPositive(r.URL.Query().Get("size"), defaultPageSize)
+	from := parseQueryParamPositive(r.URL.Query().Get("from"), 0)
+
+	params := &searchParams{
+		query: searchQuery,
+		from:  from,
+		size:  pageSize,
+		opts: []engine.SearchOption{
+			engine.WithSearchFrom(from),
+			engine.WithSearchSize(pageSize),
+		},
+	}
+
+	// restrict to the stars of a user, the repositories are tagged with the lowercased logins
+	user := strings.ToLower(r.URL.Query().Get("user"))
+	if user != "" {
+		if !slices.Contains(s.indexer.Users(), user) {
+			return nil, fmt.Errorf("unknown user %q", r.URL.Query().Get("user"))
+		}
+
+		params.opts = append(params.opts, engine.WithSearchFilter("starred_by", user))
+	}
+
+	// restrict to the archived or not archived repositories, and to the forks or not forks
+	for param, field := range map[string]string{"archived": "is_archived", "fork": "is_fork"} {
+		v := r.URL.Query().Get(param)
+		if v == "" {
+			continue
+		}
+
+		value, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", param, v)
+		}
+
+		params.opts = append(params.opts, engine.WithSearchBoolFilter(field, value))
+	}
+
+	// sorting, default is by relevance
+	if sort := r.URL.Query().Get("sort"); sort != "" {
+		sortFields, err := parseSortParam(sort, user)
+		if err != nil {
+			return nil, err
+		}
+
+		params.opts = append(params.opts, engine.WithSearchSort(sortFields...))
+	}
+
+	// highlighting, disabled by default
+	if style := r.URL.Query().Get("highlight"); style != "" {
+		if !highlightStyles[style] {
+			return nil, fmt.Errorf("invalid highlight style %q", style)
+		}
+
+		if style != "none" {
+			params.opts = append(params.opts, engine.WithSearchHighlight(style, highlightFields...))
+		}
+	}
+
+	// facets
+	if f := r.URL.Query().Get("facets"); f != "" {
+		facetOpts, err := parseFacetsParam(f)
+		if err != nil {
+			return nil, err
+		}
+
+		params.opts = append(params.opts, facetOpts...)
+	}
+
+	return params, nil
+}
+
+// parseSortParam parses the comma separated sort keys v into indexed fields.
+// A key prefixed with "-" sorts in descending order, _score always sorts the most relevant first.
+// When user is not empty, starred_at sorts by the star date of this user, given lowercased.
+// Returns an error if a key is not allowed.
+func parseSortParam(v, user string) ([]string, error) {
+	keys := strings.Split(v, ",")
+	fields := make([]string, 0, len(keys))
+	for _, key := range keys {
+		prefix := ""
+		if strings.HasPrefix(key, "-") {
+			prefix, key = "-", key[1:]
+		}
+
+		field, ok := sortKeys[key]
+		if !ok || (prefix != "" && key == "_score") {
+			return nil, fmt.Errorf("invalid sort key %q", prefix+key)
+		}
+
+		if field == "starred_at" && user != "" {
+			field = "starred_at_by." + user
+		}
+
+		fields = append(fields, prefix+field)
+	}
+
+	return fields, nil
+}
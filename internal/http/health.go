@@ -0,0 +1,50 @@
+package http
+
+import (
+	"context"
+	"net/http"
+)
+
+// ReadinessResponse is the response of the /health/ready endpoint.
+type ReadinessResponse struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]string `json:"checks"` // "ok" or the reason of the failure, by check
+}
+
+// healthHandler reports whether the server is alive, whatever the state of the index.
+func (s *server) healthHandler(w http.ResponseWriter, _ *http.Request) {
+	_, _ = w.Write([]byte("OK"))
+}
+
+// readyHandler reports whether the server is ready to answer searches: the index is open and has been synced.
+// The date of the last sync is stored in the index, so a restored index is ready too.
+// While rebuilding, the date is read from the rebuilt index but the searches use the old one, already synced.
+func (s *server) readyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.searchTimeout)
+	defer cancel()
+
+	res := &ReadinessResponse{Ready: true, Checks: map[string]string{"index": "ok", "sync": "ok"}}
+	fail := func(check, reason string) {
+		res.Ready = false
+		res.Checks[check] = reason
+	}
+
+	if _, err := s.search.DocCount(); err != nil {
+		fail("index", err.Error())
+	}
+
+	status, err := s.indexer.Status(ctx)
+	switch {
+	case err != nil:
+		fail("sync", err.Error())
+	case status.LastSyncAt == nil && !s.search.Rebuilding():
+		fail("sync", "no successful sync yet")
+	}
+
+	code := http.StatusOK
+	if !res.Ready {
+		code = http.StatusServiceUnavailable
+	}
+
+	s.responseAsJSON(w, r, code, res)
+}
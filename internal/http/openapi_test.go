@@ -0,0 +1,78 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// errorResponse is the body of the error responses.
+type errorResponse struct {
+	Code    int    `json:"code"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// declaresResponse reports whether the OpenAPI specification declares the status code for the path.
+// The specification is scanned line by line, paths are indented by two spaces.
+func declaresResponse(path string, code int) bool {
+	inPath := false
+	for _, line := range strings.Split(string(openAPISpec), "\n") {
+		switch {
+		case line == "  "+path+":":
+			inPath = true
+		case !strings.HasPrefix(line, "   "):
+			inPath = false // next path or section
+		case inPath && strings.TrimSpace(line) == strconv.Quote(strconv.Itoa(code))+":":
+			return true
+		}
+	}
+
+	return false
+}
+
+// decodeResponse checks that the status code of the response is declared for the path by the OpenAPI specification,
+// then decodes the body into v, or into an errorResponse for an error status code.
+// Unknown fields are not allowed, the body must match the response type.
+func decodeResponse(t *testing.T, path string, rec *httptest.ResponseRecorder, v any) {
+	t.Helper()
+
+	if !declaresResponse(path, rec.Code) {
+		t.Errorf("status %d of %s is not declared in openapi.yaml", rec.Code, path)
+	}
+
+	if rec.Code >= 400 {
+		v = new(errorResponse)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(rec.Body.Bytes()))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		t.Errorf("failed to decode the %d response of %s: %v", rec.Code, path, err)
+	}
+}
+
+func TestDeclaresResponse(t *testing.T) {
+	tests := []struct {
+		path string
+		code int
+		want bool
+	}{
+		{path: "/search", code: 200, want: true},
+		{path: "/search", code: 400, want: true},
+		{path: "/search", code: 404, want: false},
+		{path: "/suggest", code: 200, want: true},
+		{path: "/unknown", code: 200, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path+" "+strconv.Itoa(tt.code), func(t *testing.T) {
+			if got := declaresResponse(tt.path, tt.code); got != tt.want {
+				t.Errorf("declaresResponse() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
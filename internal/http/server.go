@@ -6,9 +6,11 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/SkYNewZ/gh-stars-search-engine/internal/engine"
+	"github.com/SkYNewZ/gh-stars-search-engine/internal/indexer"
 	"github.com/SkYNewZ/gh-stars-search-engine/internal/slogx"
 )
 
@@ -18,11 +20,25 @@ type server struct {
 	httpServer *http.Server
 
 	search        engine.Engine
+	indexer       indexer.Indexer
 	searchTimeout time.Duration
+
+	backupToken string     // token required by the backup endpoint, disabled if empty
+	backupMu    sync.Mutex // prevents concurrent backups
 }
 
-// NewServer returns a new HTTP server.
-func NewServer(logger *slog.Logger, search engine.Engine, searchTimeout time.Duration) Server {
+// Option configures the server.
+type Option func(*server)
+
+// WithBackupToken enables the /api/backup endpoint, requests must send the given token as a bearer token.
+func WithBackupToken(token string) Option {
+	return func(s *server) {
+		s.backupToken = token
+	}
+}
+
+// NewServer returns a new HTTP server searching the given engine, fed by the given indexer.
+func NewServer(logger *slog.Logger, search engine.Engine, indexer indexer.Indexer, searchTimeout time.Duration, opts ...Option) Server {
 	if logger == nil {
 		logger = slog.Default()
 	}
@@ -30,6 +46,7 @@ func NewServer(logger *slog.Logger, search engine.Engine, searchTimeout time.Dur
 	srv := &server{
 		logger:        logger,
 		search:        search,
+		indexer:       indexer,
 		searchTimeout: searchTimeout,
 		httpServer: &http.Server{
 			Addr:         "", // will be set by Start
@@ -40,9 +57,21 @@ func NewServer(logger *slog.Logger, search engine.Engine, searchTimeout time.Dur
 		},
 	}
 
+	for _, opt := range opts {
+		opt(srv)
+	}
+
 	router := http.NewServeMux()
 	router.HandleFunc("/search", srv.searchHandler)
+	router.HandleFunc("/api/v1/search", srv.searchV1Handler)
+	router.HandleFunc("/api/v1/openapi.yaml", srv.openAPIHandler)
+	router.HandleFunc("/api/suggest", srv.suggestHandler)
+	router.HandleFunc(similarPathPrefix, srv.similarHandler)
+	router.HandleFunc("/api/backup", srv.backupHandler)
+	router.HandleFunc("/api/stats", srv.statsHandler)
 	router.HandleFunc("/health", srv.healthHandler)
+	router.HandleFunc("/health/live", srv.healthHandler)
+	router.HandleFunc("/health/ready", srv.readyHandler)
 	router.HandleFunc("/", srv.uiHandler)
 
 	// setup default middlewares
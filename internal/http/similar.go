@@ -0,0 +1,54 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/SkYNewZ/gh-stars-search-engine/internal/engine"
+)
+
+// similarPathPrefix and similarPathSuffix surround the repository ID in the path of the similar repositories endpoint.
+const (
+	similarPathPrefix = "/api/repos/"
+	similarPathSuffix = "/similar"
+)
+
+// similarHandler returns the starred repositories similar to the one having the ID given in the path,
+// /api/repos/{id}/similar, as a SearchResponse.
+func (s *server) similarHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, similarPathPrefix), similarPathSuffix)
+	if !ok || id == "" || strings.Contains(id, "/") {
+		s.responseErrorAsJSON(w, r, http.StatusNotFound, "not found")
+		return
+	}
+
+	pageSize := parseQueryParamPositive(r.URL.Query().Get("size"), defaultPageSize)
+	from := parseQueryParamPositive(r.URL.Query().Get("from"), 0)
+	params := &searchParams{
+		from: from,
+		size: pageSize,
+		opts: []engine.SearchOption{
+			engine.WithSearchFrom(from),
+			engine.WithSearchSize(pageSize),
+			engine.WithSearchFields(repositoryFields...),
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.searchTimeout)
+	defer cancel()
+
+	res, err := s.search.Similar(ctx, id, params.opts...)
+	if errors.Is(err, engine.ErrNotFound) {
+		s.responseErrorAsJSON(w, r, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if err != nil {
+		s.responseErrorAsJSON(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s.responseAsJSON(w, r, http.StatusOK, newSearchResponse(r, params, res))
+}
@@ -0,0 +1,63 @@
+package http
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/blevesearch/bleve/v2"
+
+	"github.com/SkYNewZ/gh-stars-search-engine/internal/engine"
+	"github.com/SkYNewZ/gh-stars-search-engine/internal/indexer"
+)
+
+// statsLanguagesSize is the number of languages of the stats breakdown.
+const statsLanguagesSize int = 100
+
+// StatsResponse is the response of the /api/stats endpoint.
+type StatsResponse struct {
+	Index     *engine.Stats    `json:"index"`
+	Sync      *indexer.Status  `json:"sync"`
+	Languages []*LanguageCount `json:"languages"` // most frequent primary languages first
+}
+
+// LanguageCount is the number of repositories having a primary language.
+type LanguageCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// statsHandler returns the statistics of the index and the state of the stars synchronisation.
+func (s *server) statsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), s.searchTimeout)
+	defer cancel()
+
+	stats, err := s.search.Stats(ctx)
+	if err != nil {
+		s.responseErrorAsJSON(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	status, err := s.indexer.Status(ctx)
+	if err != nil {
+		s.responseErrorAsJSON(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	res, err := s.search.Search(ctx, bleve.NewMatchAllQuery(),
+		engine.WithSearchSize(0),
+		engine.WithSearchTermFacet("language", "primary_language.name", statsLanguagesSize),
+	)
+	if err != nil {
+		s.responseErrorAsJSON(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	languages := make([]*LanguageCount, 0)
+	if facet, ok := res.Facets["language"]; ok && facet.Terms != nil {
+		for _, term := range facet.Terms.Terms() {
+			languages = append(languages, &LanguageCount{Name: term.Term, Count: term.Count})
+		}
+	}
+
+	s.responseAsJSON(w, r, http.StatusOK, &StatsResponse{Index: stats, Sync: status, Languages: languages})
+}
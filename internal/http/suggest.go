@@ -0,0 +1,73 @@
+package http
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/SkYNewZ/gh-stars-search-engine/internal/engine"
+)
+
+const (
+	defaultSuggestSize int = 10
+	maxSuggestSize     int = 50
+)
+
+// suggestFields maps the lowercased fields completions are taken from to their suggestion type.
+var suggestFields = map[string]string{
+	"name_with_owner":                 "name",
+	"owner.words":                     "owner",
+	"topics":                          "topic",
+	"primary_language.name_lowercase": "language",
+}
+
+// SuggestResponse is the response of the /api/suggest endpoint.
+type SuggestResponse struct {
+	Suggestions []*Suggestion `json:"suggestions"`
+}
+
+// Suggestion is a completion of the prefix.
+type Suggestion struct {
+	Text  string `json:"text"`
+	Type  string `json:"type"`  // name, owner, topic or language
+	Count uint64 `json:"count"` // number of repositories having the completion
+}
+
+func (s *server) suggestHandler(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Query().Get("prefix")
+	if prefix == "" {
+		s.responseErrorAsJSON(w, r, http.StatusBadRequest, "missing prefix query param")
+		return
+	}
+
+	size := min(parseQueryParamPositive(r.URL.Query().Get("size"), defaultSuggestSize), maxSuggestSize)
+
+	fields := make([]string, 0, len(suggestFields))
+	for field := range suggestFields {
+		fields = append(fields, field)
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), s.searchTimeout)
+	defer cancel()
+
+	suggestions, err := s.search.Suggest(ctx, prefix, size, fields...)
+	if err != nil {
+		s.responseErrorAsJSON(w, r, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	s.responseAsJSON(w, r, http.StatusOK, newSuggestResponse(suggestions))
+}
+
+// newSuggestResponse converts the engine suggestions into a SuggestResponse.
+func newSuggestResponse(suggestions []*engine.Suggestion) *SuggestResponse {
+	resp := &SuggestResponse{Suggestions: make([]*Suggestion, 0, len(suggestions))}
+	for _, suggestion := range suggestions {
+		resp.Suggestions = append(resp.Suggestions, &Suggestion{
+			Text:  suggestion.Term,
+			Type:  suggestFields[suggestion.Field],
+			Count: suggestion.Count,
+		})
+	}
+
+	return resp
+}
@@ -0,0 +1,108 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/SkYNewZ/gh-stars-search-engine/internal/github"
+	"github.com/SkYNewZ/gh-stars-search-engine/internal/slogx"
+)
+
+// starsKeyPrefix prefixes the metadata key holding the stars of an account.
+const starsKeyPrefix string = "stars/"
+
+// account is a GitHub user whose stars are indexed.
+type account struct {
+	login  string
+	client github.Client
+
+	// err is the failure to resolve the login, the stars of the account are not synced
+	err error
+
+	// stars are the star dates of the repositories starred by the user, by repository ID
+	stars map[string]time.Time
+}
+
+// loadAccounts resolves the login of each client and loads the stars already indexed for them.
+// A client whose login cannot be resolved is returned with its error so that the other accounts are still synced.
+// Its stars are loaded when its login is known from a previous sync, they keep being tagged with it.
+func (i *indexer) loadAccounts(ctx context.Context) ([]*account, error) {
+	accounts := make([]*account, 0, len(i.clients))
+	for n, client := range i.clients {
+		acc := &account{client: client, stars: make(map[string]time.Time)}
+		login, err := client.Login(ctx)
+		if err != nil {
+			acc.login = i.login(n)
+			acc.err = fmt.Errorf("failed to resolve login of user %d: %w", n+1, err)
+			i.logger.With(slogx.Err(err), slog.String("login", acc.login)).Warn("failed to resolve login, skipping user")
+		} else {
+			acc.login = login
+			i.setLogin(n, login)
+		}
+
+		if err := i.loadStars(acc); err != nil {
+			return nil, err
+		}
+
+		accounts = append(accounts, acc)
+	}
+
+	return accounts, nil
+}
+
+// loadStars loads the stars already indexed for the given account, none if its login is unknown.
+func (i *indexer) loadStars(acc *account) error {
+	if acc.login == "" {
+		return nil
+	}
+
+	value, err := i.engine.GetMetadata(starsKeyPrefix + acc.login)
+	if err != nil || value == nil {
+		return err
+	}
+
+	if err := json.Unmarshal(value, &acc.stars); err != nil {
+		return fmt.Errorf("failed to parse stars of %s: %w", acc.login, err)
+	}
+
+	return nil
+}
+
+// saveStars persists the stars of the given account.
+func (i *indexer) saveStars(acc *account) error {
+	value, err := json.Marshal(acc.stars)
+	if err != nil {
+		return fmt.Errorf("failed to encode stars of %s: %w", acc.login, err)
+	}
+
+	return i.engine.SetMetadata(starsKeyPrefix+acc.login, value)
+}
+
+// tag sets the users who starred the given repository, with their star date.
+// The logins are lowercased, GitHub logins being case insensitive, see Users.
+// The repository star date is the most recent one.
+func tag(repo *github.Repository, accounts []*account) {
+	repo.StarredBy = make([]string, 0, 1)
+	repo.StarredAtBy = make(map[string]time.Time, 1)
+	repo.StarredAt = time.Time{}
+
+	for _, acc := range accounts {
+		starredAt, ok := acc.stars[repo.GetID()]
+		if !ok {
+			continue
+		}
+
+		login := strings.ToLower(acc.login)
+		repo.StarredBy = append(repo.StarredBy, login)
+		repo.StarredAtBy[login] = starredAt
+		if starredAt.After(repo.StarredAt) {
+			repo.StarredAt = starredAt
+		}
+	}
+
+	repo.StarredByCount = len(repo.StarredBy)
+}
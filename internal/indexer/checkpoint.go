@@ -0,0 +1,71 @@
+package indexer
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// checkpointKeyPrefix prefixes the metadata key holding the progress of an unfinished sync of an account.
+const checkpointKeyPrefix string = "sync_checkpoint/"
+
+// checkpoint is the progress of a sync, persisted after each indexed batch.
+type checkpoint struct {
+	// Full reports whether the sync fetches all the stars.
+	Full bool `json:"full"`
+
+	// Since is the date of the oldest star to fetch.
+	Since time.Time `json:"since"`
+
+	// Newest is the date of the most recent star fetched so far.
+	Newest time.Time `json:"newest"`
+
+	// Cursor is the position of the last indexed star.
+	Cursor string `json:"cursor"`
+}
+
+// checkpoint returns the checkpoint of the unfinished sync of the given account if any, or a new one starting a sync.
+// An unfinished incremental sync is discarded when a full sync is requested.
+func (i *indexer) checkpoint(login string, full bool) (*checkpoint, error) {
+	value, err := i.engine.GetMetadata(checkpointKeyPrefix + login)
+	if err != nil {
+		return nil, err
+	}
+
+	if value != nil {
+		var cp checkpoint
+		if err := json.Unmarshal(value, &cp); err != nil {
+			return nil, fmt.Errorf("failed to parse checkpoint of %s: %w", login, err)
+		}
+
+		if cp.Full || !full {
+			return &cp, nil
+		}
+	}
+
+	cp := &checkpoint{Full: full}
+	if !full {
+		if cp.Since, err = i.lastStarredAt(login); err != nil {
+			return nil, err
+		}
+
+		cp.Newest = cp.Since
+	}
+
+	return cp, nil
+}
+
+// saveCheckpoint persists the given checkpoint of the given account.
+func (i *indexer) saveCheckpoint(login string, cp *checkpoint) error {
+	value, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint of %s: %w", login, err)
+	}
+
+	return i.engine.SetMetadata(checkpointKeyPrefix+login, value)
+}
+
+// clearCheckpoint removes the checkpoint of the given account once its sync is complete.
+func (i *indexer) clearCheckpoint(login string) error {
+	return i.engine.DeleteMetadata(checkpointKeyPrefix + login)
+}
@@ -0,0 +1,360 @@
+package indexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/SkYNewZ/gh-stars-search-engine/internal/engine"
+	"github.com/SkYNewZ/gh-stars-search-engine/internal/github"
+	"github.com/SkYNewZ/gh-stars-search-engine/internal/slogx"
+)
+
+// lastStarredAtKeyPrefix prefixes the metadata key holding the date of the most recent indexed star of an account.
+const lastStarredAtKeyPrefix string = "last_starred_at/"
+
+// ErrSyncInProgress is returned when a sync is requested while another one is running.
+var ErrSyncInProgress = errors.New("sync already in progress")
+
+//go:generate go run github.com/vburenin/ifacemaker --file $GOFILE --file status.go --struct indexer --iface Indexer --pkg indexer --output indexer_iface.go
+type indexer struct {
+	clients   []github.Client
+	engine    engine.Engine
+	logger    *slog.Logger
+	batchSize int
+
+	mu sync.Mutex // prevents concurrent syncs
+
+	statusMu    sync.RWMutex
+	logins      []string // login of each client, empty until resolved by a sync
+	syncing     bool
+	lastSyncAt  time.Time // end of the last successful sync, loaded from the metadata if zero
+	lastError   error
+	lastErrorAt time.Time
+}
+
+// New returns a new Indexer fetching the stars of each given client's user into the given engine.
+// A repository starred by several users is indexed once, tagged with all of them.
+func New(clients []github.Client, engine engine.Engine, logger *slog.Logger, batchSize int) Indexer {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &indexer{
+		clients:   clients,
+		logins:    make([]string, len(clients)),
+		engine:    engine,
+		logger:    logger,
+		batchSize: batchSize,
+	}
+}
+
+// Sync fetches the stars of every user and indexes them.
+// When full is false, only the stars newer than the last indexed one are fetched.
+// When full is true, all the stars are fetched again and unstarred repositories are removed from the index.
+// Stars are indexed and checkpointed batch by batch, an interrupted sync is resumed by the next one.
+// A failing user does not prevent the others from being synced.
+// While the index is rebuilt for a new mapping, every sync is full and the first complete one swaps the indexes.
+func (i *indexer) Sync(ctx context.Context, full bool) error {
+	if !i.mu.TryLock() {
+		return ErrSyncInProgress
+	}
+	defer i.mu.Unlock()
+
+	i.setSyncing()
+	err := i.sync(ctx, full)
+	i.setSynced(err)
+
+	return err
+}
+
+// sync runs a sync, see Sync.
+func (i *indexer) sync(ctx context.Context, full bool) error {
+	rebuilding := i.engine.Rebuilding()
+	if rebuilding && !full {
+		i.logger.Info("index is being rebuilt, running a full sync")
+		full = true
+	}
+
+	// stop the listings if we return early
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	accounts, err := i.loadAccounts(ctx)
+	if err != nil {
+		return err
+	}
+
+	lists := i.listsByRepository(ctx, accounts)
+
+	// a full sync keeps the fetched repositories to update their stargazers once every user is synced
+	var fetched map[string]*github.Repository
+	if full {
+		fetched = make(map[string]*github.Repository)
+	}
+
+	complete := true
+	errs := make([]error, 0)
+	for _, acc := range accounts {
+		if acc.err != nil {
+			errs = append(errs, acc.err)
+			complete = false
+			continue
+		}
+
+		resumed, err := i.syncAccount(ctx, acc, accounts, full, lists, fetched)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to sync stars of %s: %w", acc.login, err))
+		}
+
+		complete = complete && err == nil && !resumed
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	if !full {
+		return nil
+	}
+
+	// removing unstarred repositories requires the complete list of stars of every user
+	if !complete {
+		i.logger.Warn("full sync has been resumed, skipping the removal of unstarred repositories")
+		return nil
+	}
+
+	if err := i.retag(fetched, accounts); err != nil {
+		return err
+	}
+
+	if err := i.prune(ctx, accounts); err != nil {
+		return err
+	}
+
+	if !rebuilding {
+		return nil
+	}
+
+	if err := i.engine.CompleteRebuild(); err != nil {
+		return fmt.Errorf("failed to complete index rebuild: %w", err)
+	}
+
+	return nil
+}
+
+// syncAccount fetches the stars of the given account and indexes them, tagged with every account who starred them.
+// When fetched is not nil, the fetched repositories are added to it.
+// Returns whether an unfinished full sync has been resumed.
+func (i *indexer) syncAccount(
+	ctx context.Context,
+	acc *account,
+	accounts []*account,
+	full bool,
+	lists map[string][]string,
+	fetched map[string]*github.Repository,
+) (bool, error) {
+	cp, err := i.checkpoint(acc.login, full)
+	if err != nil {
+		return false, err
+	}
+
+	resumed := cp.Cursor != ""
+	i.logger.With(
+		slog.String("login", acc.login),
+		slog.Bool("full", cp.Full),
+		slog.Time("since", cp.Since),
+		slog.Bool("resumed", resumed),
+	).Info("fetching stars")
+
+	seen := make(map[string]time.Time)
+	batch := make([]engine.Indexable, 0, i.batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		if err := i.engine.BatchIndex(batch, i.batchSize); err != nil {
+			return fmt.Errorf("failed to index stars: %w", err)
+		}
+
+		batch = batch[:0]
+		if err := i.saveStars(acc); err != nil {
+			return err
+		}
+
+		return i.saveCheckpoint(acc.login, cp)
+	}
+
+	stars, errs := acc.client.GetStarsSince(ctx, cp.Since, cp.Cursor)
+	for starredRepo := range stars {
+		repo := starredRepo.Repository
+		acc.stars[repo.GetID()] = starredRepo.StarredAt
+		seen[repo.GetID()] = starredRepo.StarredAt
+		repo.Lists = lists[repo.GetID()]
+		tag(repo, accounts)
+
+		batch = append(batch, repo)
+		if fetched != nil {
+			fetched[repo.GetID()] = repo
+		}
+
+		cp.Cursor = starredRepo.Cursor
+		if starredRepo.StarredAt.After(cp.Newest) {
+			cp.Newest = starredRepo.StarredAt
+		}
+
+		if len(batch) >= i.batchSize {
+			if err := flush(); err != nil {
+				return resumed, err
+			}
+		}
+	}
+
+	// index what has been fetched anyway, the checkpoint allows the next sync to resume from there
+	fetchErr := <-errs
+	if err := flush(); err != nil {
+		return resumed, err
+	}
+
+	// an incomplete listing must neither forget stars nor move the last starred date,
+	// otherwise the missing stars would be removed or skipped by the next sync
+	if fetchErr != nil {
+		return resumed, fmt.Errorf("failed to fetch stars, %d fetched: %w", len(seen), fetchErr)
+	}
+
+	i.logger.Debug(fmt.Sprintf("indexed %d stars of %s", len(seen), acc.login))
+	if cp.Full && !resumed {
+		acc.stars = seen // forget the unstarred repositories
+		if err := i.saveStars(acc); err != nil {
+			return resumed, err
+		}
+	}
+
+	if err := i.setLastStarredAt(acc.login, cp.Newest); err != nil {
+		return resumed, err
+	}
+
+	return resumed, i.clearCheckpoint(acc.login)
+}
+
+// retag indexes again the fetched repositories whose stargazers changed since they were indexed,
+// such as a repository unstarred by a user synced after it.
+func (i *indexer) retag(fetched map[string]*github.Repository, accounts []*account) error {
+	changed := make([]engine.Indexable, 0)
+	for _, repo := range fetched {
+		before := repo.StarredBy
+		tag(repo, accounts)
+		if !slices.Equal(before, repo.StarredBy) {
+			changed = append(changed, repo)
+		}
+	}
+
+	if len(changed) == 0 {
+		return nil
+	}
+
+	i.logger.Debug(fmt.Sprintf("updating stargazers of %d repositories", len(changed)))
+	if err := i.engine.BatchIndex(changed, i.batchSize); err != nil {
+		return fmt.Errorf("failed to update stargazers: %w", err)
+	}
+
+	return nil
+}
+
+// listsByRepository returns the slugs of the star lists of every account containing each repository, by repository ID.
+// Lists are optional, a failure is only logged and results in no list for this account.
+// Only the fetched repositories get their lists updated: a full sync is needed to refresh older stars.
+func (i *indexer) listsByRepository(ctx context.Context, accounts []*account) map[string][]string {
+	byRepo := make(map[string][]string)
+	for _, acc := range accounts {
+		if acc.err != nil {
+			continue
+		}
+
+		lists, err := acc.client.GetLists(ctx)
+		if err != nil {
+			i.logger.With(slogx.Err(err), slog.String("login", acc.login)).Warn("failed to fetch star lists, indexing stars without lists")
+			continue
+		}
+
+		for _, list := range lists {
+			for _, id := range list.RepositoryIDs {
+				if !slices.Contains(byRepo[id], list.Slug) {
+					byRepo[id] = append(byRepo[id], list.Slug)
+				}
+			}
+		}
+	}
+
+	return byRepo
+}
+
+// prune removes from the index the documents which are not starred by any of the given accounts anymore.
+func (i *indexer) prune(ctx context.Context, accounts []*account) error {
+	keep := make(map[string]struct{})
+	for _, acc := range accounts {
+		for id := range acc.stars {
+			keep[id] = struct{}{}
+		}
+	}
+
+	ids, err := i.engine.IDs(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list indexed stars: %w", err)
+	}
+
+	stale := make([]string, 0)
+	for _, id := range ids {
+		if _, ok := keep[id]; !ok {
+			stale = append(stale, id)
+		}
+	}
+
+	if len(stale) == 0 {
+		i.logger.Debug("no unstarred repository to remove")
+		return nil
+	}
+
+	if err := i.engine.Delete(stale...); err != nil {
+		return fmt.Errorf("failed to remove unstarred repositories: %w", err)
+	}
+
+	i.logger.Info(fmt.Sprintf("removed %d unstarred repositories", len(stale)))
+	return nil
+}
+
+// lastStarredAt returns the date of the most recent indexed star of the given account.
+// Returns a zero time if nothing has been indexed yet.
+func (i *indexer) lastStarredAt(login string) (time.Time, error) {
+	var t time.Time
+
+	value, err := i.engine.GetMetadata(lastStarredAtKeyPrefix + login)
+	if err != nil || value == nil {
+		return t, err
+	}
+
+	if err := t.UnmarshalText(value); err != nil {
+		return t, fmt.Errorf("failed to parse last starred date of %s: %w", login, err)
+	}
+
+	return t, nil
+}
+
+// setLastStarredAt persists the date of the most recent indexed star of the given account.
+func (i *indexer) setLastStarredAt(login string, t time.Time) error {
+	if t.IsZero() {
+		return nil // nothing indexed yet
+	}
+
+	value, err := t.MarshalText()
+	if err != nil {
+		return fmt.Errorf("failed to encode last starred date of %s: %w", login, err)
+	}
+
+	return i.engine.SetMetadata(lastStarredAtKeyPrefix+login, value)
+}
@@ -0,0 +1,282 @@
+package indexer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/SkYNewZ/gh-stars-search-engine/internal/engine"
+	"github.com/SkYNewZ/gh-stars-search-engine/internal/github"
+)
+
+// fakeClient is a GitHub client listing the given stars, or failing to resolve its login.
+type fakeClient struct {
+	github.Client // unused methods panic
+
+	login    string
+	loginErr error
+	stars    []*github.StarredRepository // from the most recently starred to the oldest one, as listed by GitHub
+	starsErr error                      // interrupts the listing once the stars are listed
+	calls    []starsCall                // arguments of each GetStarsSince call
+}
+
+// starsCall are the arguments of a GetStarsSince call.
+type starsCall struct {
+	since  time.Time
+	cursor string
+}
+
+func (c *fakeClient) Login(context.Context) (string, error) {
+	return c.login, c.loginErr
+}
+
+func (c *fakeClient) GetStarsSince(_ context.Context, since time.Time, cursor string) (<-chan *github.StarredRepository, <-chan error) {
+	c.calls = append(c.calls, starsCall{since: since, cursor: cursor})
+
+	stars := make(chan *github.StarredRepository, len(c.stars))
+	errs := make(chan error, 1)
+	resumed := cursor == ""
+	for _, star := range c.stars {
+		if star.StarredAt.Before(since) {
+			break
+		}
+
+		if resumed {
+			repo := *star.Repository // the indexer tags the listed repositories
+			stars <- &github.StarredRepository{Cursor: star.Cursor, StarredAt: star.StarredAt, Repository: &repo}
+		}
+
+		resumed = resumed || star.Cursor == cursor
+	}
+
+	close(stars)
+	errs <- c.starsErr
+	close(errs)
+	return stars, errs
+}
+
+func (c *fakeClient) RateLimit() *github.RateLimit {
+	return nil
+}
+
+func (c *fakeClient) GetLists(context.Context) ([]*github.List, error) {
+	return nil, nil
+}
+
+// recordingEngine is an engine recording the indexed and deleted documents.
+type recordingEngine struct {
+	engine.Engine
+
+	indexed []string                      // IDs of the indexed documents, in order
+	docs    map[string]*github.Repository // last indexed version of each document
+	deleted []string                      // IDs of the deleted documents, in order
+}
+
+func (e *recordingEngine) BatchIndex(data []engine.Indexable, batchSize int) error {
+	if e.docs == nil {
+		e.docs = make(map[string]*github.Repository)
+	}
+
+	for _, doc := range data {
+		e.indexed = append(e.indexed, doc.GetID())
+		repo := *doc.(*github.Repository) // the indexer keeps tagging the fetched repositories
+		e.docs[doc.GetID()] = &repo
+	}
+
+	return e.Engine.BatchIndex(data, batchSize)
+}
+
+func (e *recordingEngine) Delete(ids ...string) error {
+	e.deleted = append(e.deleted, ids...)
+	return e.Engine.Delete(ids...)
+}
+
+// newStar returns a star of the repository having the given ID, its cursor being the ID.
+func newStar(id string, starredAt time.Time) *github.StarredRepository {
+	return &github.StarredRepository{
+		Cursor:     id,
+		StarredAt:  starredAt,
+		Repository: &github.Repository{ID: id, NameWithOwner: "octocat/" + id},
+	}
+}
+
+// newTestIndexer returns an indexer of the given clients into an in-memory engine, indexing stars by batches of 2.
+func newTestIndexer(t *testing.T, clients ...github.Client) (*indexer, engine.Engine) {
+	t.Helper()
+
+	e, err := engine.New("", nil, nil, engine.WithMemoryStorage())
+	if err != nil {
+		t.Fatalf("engine.New() error = %v", err)
+	}
+
+	return New(clients, e, slog.New(slog.NewTextHandler(io.Discard, nil)), 2).(*indexer), e
+}
+
+// indexedIDs returns the sorted IDs of the indexed documents.
+func indexedIDs(t *testing.T, e engine.Engine) []string {
+	t.Helper()
+
+	ids, err := e.IDs(context.Background())
+	if err != nil {
+		t.Fatalf("IDs() error = %v", err)
+	}
+
+	slices.Sort(ids)
+	return ids
+}
+
+func TestSyncFailingLogin(t *testing.T) {
+	ctx := context.Background()
+	loginErr := errors.New("bad credentials")
+	i, e := newTestIndexer(t,
+		&fakeClient{loginErr: loginErr},
+		&fakeClient{login: "octocat", stars: []*github.StarredRepository{newStar("R_1", time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC))}},
+	)
+
+	if err := i.Sync(ctx, true); !errors.Is(err, loginErr) {
+		t.Fatalf("Sync() error = %v, want %v", err, loginErr)
+	}
+
+	if ids := indexedIDs(t, e); !slices.Equal(ids, []string{"R_1"}) {
+		t.Errorf("IDs() = %v, want [R_1], the stars of octocat are synced", ids)
+	}
+
+	status, err := i.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+
+	if status.LastError == "" || status.LastErrorAt == nil {
+		t.Errorf("Status() = %+v, want the login error", status)
+	}
+}
+
+func TestSyncCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	starredAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	client := &fakeClient{
+		login:    "octocat",
+		stars:    []*github.StarredRepository{newStar("R_3", starredAt), newStar("R_2", starredAt.Add(-time.Hour)), newStar("R_1", starredAt.Add(-2*time.Hour))},
+		starsErr: errors.New("connection reset"),
+	}
+
+	i, e := newTestIndexer(t, client)
+
+	// the interrupted listing keeps its progress
+	if err := i.Sync(ctx, false); !errors.Is(err, client.starsErr) {
+		t.Fatalf("Sync() error = %v, want %v", err, client.starsErr)
+	}
+
+	if ids := indexedIDs(t, e); !slices.Equal(ids, []string{"R_1", "R_2", "R_3"}) {
+		t.Errorf("IDs() = %v, want the stars fetched before the failure", ids)
+	}
+
+	value, err := e.GetMetadata(checkpointKeyPrefix + "octocat")
+	if err != nil {
+		t.Fatalf("GetMetadata() error = %v", err)
+	}
+
+	var cp checkpoint
+	if err := json.Unmarshal(value, &cp); err != nil || cp.Cursor != "R_1" || !cp.Newest.Equal(starredAt) {
+		t.Fatalf("checkpoint = %+v (error %v), want the cursor R_1 and the newest star at %s", cp, err, starredAt)
+	}
+
+	// the next sync resumes the listing after the last indexed star, then forgets the checkpoint
+	client.starsErr = nil
+	if err := i.Sync(ctx, false); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	if got := client.calls[1]; got.cursor != "R_1" || !got.since.IsZero() {
+		t.Errorf("GetStarsSince() called with since %s and cursor %q, want the checkpoint cursor R_1", got.since, got.cursor)
+	}
+
+	if value, err := e.GetMetadata(checkpointKeyPrefix + "octocat"); err != nil || value != nil {
+		t.Errorf("checkpoint = %s (error %v), want none once the sync is complete", value, err)
+	}
+
+	lastStarredAt, err := i.lastStarredAt("octocat")
+	if err != nil || !lastStarredAt.Equal(starredAt) {
+		t.Errorf("lastStarredAt() = %s (error %v), want %s", lastStarredAt, err, starredAt)
+	}
+}
+
+func TestSyncIncremental(t *testing.T) {
+	ctx := context.Background()
+	starredAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	client := &fakeClient{login: "octocat", stars: []*github.StarredRepository{newStar("R_2", starredAt), newStar("R_1", starredAt.Add(-time.Hour))}}
+	i, e := newTestIndexer(t, client)
+	recorder := &recordingEngine{Engine: e}
+	i.engine = recorder
+
+	if err := i.Sync(ctx, false); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	// a new star, only it is indexed by the next incremental sync
+	newer := starredAt.Add(time.Hour)
+	client.stars = append([]*github.StarredRepository{newStar("R_3", newer)}, client.stars...)
+	recorder.indexed = nil
+	if err := i.Sync(ctx, false); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	if got := client.calls[1].since; !got.Equal(starredAt) {
+		t.Errorf("GetStarsSince() since = %s, want the last indexed star at %s", got, starredAt)
+	}
+
+	// the last known star is listed again, its date being the cutoff
+	if !slices.Equal(recorder.indexed, []string{"R_3", "R_2"}) {
+		t.Errorf("indexed = %v, want [R_3 R_2], the stars newer than the last indexed one", recorder.indexed)
+	}
+
+	if ids := indexedIDs(t, e); !slices.Equal(ids, []string{"R_1", "R_2", "R_3"}) {
+		t.Errorf("IDs() = %v, want [R_1 R_2 R_3]", ids)
+	}
+}
+
+func TestSyncUnstarred(t *testing.T) {
+	ctx := context.Background()
+	starredAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	bob := &fakeClient{login: "Bob", stars: []*github.StarredRepository{newStar("R_2", starredAt)}}
+	alice := &fakeClient{login: "alice", stars: []*github.StarredRepository{newStar("R_2", starredAt), newStar("R_1", starredAt)}}
+	i, e := newTestIndexer(t, bob, alice)
+	recorder := &recordingEngine{Engine: e}
+	i.engine = recorder
+
+	if err := i.Sync(ctx, true); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	// alice unstars both repositories, bob still stars R_2
+	alice.stars = nil
+	if err := i.Sync(ctx, true); err != nil {
+		t.Fatalf("Sync() error = %v", err)
+	}
+
+	if !slices.Equal(recorder.deleted, []string{"R_1"}) {
+		t.Errorf("deleted = %v, want [R_1], unstarred by every user", recorder.deleted)
+	}
+
+	// bob is synced first, R_2 is retagged once alice's unstar is known, with the lowercased login
+	if got := recorder.docs["R_2"].StarredBy; !slices.Equal(got, []string{"bob"}) {
+		t.Errorf("R_2 starred by %v, want [bob]", got)
+	}
+
+	if _, ok := recorder.docs["R_2"].StarredAtBy["bob"]; !ok {
+		t.Errorf("R_2 star dates = %v, want the star date of bob", recorder.docs["R_2"].StarredAtBy)
+	}
+
+	if got := i.Users(); !slices.Equal(got, []string{"bob", "alice"}) {
+		t.Errorf("Users() = %v, want [bob alice]", got)
+	}
+
+	if ids := indexedIDs(t, e); !slices.Equal(ids, []string{"R_2"}) {
+		t.Errorf("IDs() = %v, want [R_2]", ids)
+	}
+}
@@ -0,0 +1,127 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/SkYNewZ/gh-stars-search-engine/internal/github"
+	"github.com/SkYNewZ/gh-stars-search-engine/internal/slogx"
+)
+
+// lastSyncAtKey is the metadata key holding the end date of the last successful sync.
+const lastSyncAtKey string = "last_sync_at"
+
+// Status is the state of the stars synchronisation.
+type Status struct {
+	Syncing     bool                         `json:"syncing"`
+	LastSyncAt  *time.Time                   `json:"last_sync_at"`            // end of the last successful sync, nil if none
+	LastError   string                       `json:"last_error,omitempty"`    // error of the last failed sync
+	LastErrorAt *time.Time                   `json:"last_error_at,omitempty"` // end of the last failed sync
+	RateLimits  map[string]*github.RateLimit `json:"rate_limits"`             // last known GitHub API rate limit by login
+}
+
+// Status returns the state of the stars synchronisation.
+// The date of the last successful sync is kept across restarts, the last error is not.
+// It does not query the GitHub API, the rate limits are known for the users resolved by a sync.
+func (i *indexer) Status(ctx context.Context) (*Status, error) {
+	i.statusMu.RLock()
+	status := &Status{Syncing: i.syncing, RateLimits: make(map[string]*github.RateLimit, len(i.clients))}
+	lastSyncAt := i.lastSyncAt
+	if i.lastError != nil {
+		lastErrorAt := i.lastErrorAt
+		status.LastError = i.lastError.Error()
+		status.LastErrorAt = &lastErrorAt
+	}
+
+	// the logins are only known once resolved by a sync, resolving them here could query the API
+	for n, login := range i.logins {
+		if login != "" {
+			status.RateLimits[login] = i.clients[n].RateLimit()
+		}
+	}
+	i.statusMu.RUnlock()
+
+	if lastSyncAt.IsZero() {
+		value, err := i.engine.GetMetadata(lastSyncAtKey)
+		if err != nil {
+			return nil, err
+		}
+
+		if value != nil {
+			if lastSyncAt, err = time.Parse(time.RFC3339, string(value)); err != nil {
+				return nil, fmt.Errorf("failed to parse last sync date: %w", err)
+			}
+		}
+	}
+
+	if !lastSyncAt.IsZero() {
+		status.LastSyncAt = &lastSyncAt
+	}
+
+	return status, nil
+}
+
+// Users returns the lowercased logins of the users, as the repositories are tagged with them.
+// A user is only known once its login has been resolved by a sync.
+func (i *indexer) Users() []string {
+	i.statusMu.RLock()
+	defer i.statusMu.RUnlock()
+
+	users := make([]string, 0, len(i.logins))
+	for _, login := range i.logins {
+		if login != "" {
+			users = append(users, strings.ToLower(login))
+		}
+	}
+
+	return users
+}
+
+// login returns the login of the n-th client, empty until resolved by a sync.
+func (i *indexer) login(n int) string {
+	i.statusMu.RLock()
+	defer i.statusMu.RUnlock()
+
+	return i.logins[n]
+}
+
+// setLogin records the login of the n-th client.
+func (i *indexer) setLogin(n int, login string) {
+	i.statusMu.Lock()
+	defer i.statusMu.Unlock()
+
+	i.logins[n] = login
+}
+
+// setSyncing records the start of a sync.
+func (i *indexer) setSyncing() {
+	i.statusMu.Lock()
+	defer i.statusMu.Unlock()
+
+	i.syncing = true
+}
+
+// setSynced records the end of a sync and its error, if any.
+// The date of a successful sync is persisted in the metadata.
+func (i *indexer) setSynced(err error) {
+	now := time.Now()
+
+	i.statusMu.Lock()
+	i.syncing = false
+	if err != nil {
+		i.lastError, i.lastErrorAt = err, now
+	} else {
+		i.lastSyncAt = now
+	}
+	i.statusMu.Unlock()
+
+	if err != nil {
+		return
+	}
+
+	if err := i.engine.SetMetadata(lastSyncAtKey, []byte(now.UTC().Format(time.RFC3339))); err != nil {
+		i.logger.With(slogx.Err(err)).Warn("failed to persist last sync date")
+	}
+}
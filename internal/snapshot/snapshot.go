@@ -0,0 +1,145 @@
+package snapshot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SkYNewZ/gh-stars-search-engine/internal/engine"
+	"github.com/SkYNewZ/gh-stars-search-engine/internal/slogx"
+)
+
+const (
+	// filePrefix and fileSuffix surround the creation date of the snapshot archives, so that they sort by date.
+	filePrefix string = "ghs-"
+	fileSuffix string = ".tar.gz"
+
+	// timeLayout is the layout of the creation date in the file names.
+	timeLayout string = "20060102T150405Z"
+)
+
+// ErrSnapshotInProgress is returned when a snapshot is requested while another one is running.
+var ErrSnapshotInProgress = errors.New("snapshot already in progress")
+
+//go:generate go run github.com/vburenin/ifacemaker --file $GOFILE --struct snapshotter --iface Snapshotter --pkg snapshot --output snapshotter_iface.go
+type snapshotter struct {
+	engine    engine.Engine
+	dir       string
+	retention int
+	logger    *slog.Logger
+
+	mu sync.Mutex // prevents concurrent snapshots
+}
+
+// New returns a new Snapshotter writing the backups of the given engine in the given directory.
+// Only the given number of most recent backups are kept.
+func New(engine engine.Engine, dir string, retention int, logger *slog.Logger) Snapshotter {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &snapshotter{
+		engine:    engine,
+		dir:       dir,
+		retention: retention,
+		logger:    logger,
+	}
+}
+
+// Snapshot writes a backup of the engine in the snapshot directory, then removes the backups exceeding the retention.
+// Returns the path of the backup. See engine.Restore to restore it.
+func (s *snapshotter) Snapshot(ctx context.Context) (string, error) {
+	if !s.mu.TryLock() {
+		return "", ErrSnapshotInProgress
+	}
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+
+	path := filepath.Join(s.dir, filePrefix+time.Now().UTC().Format(timeLayout)+fileSuffix)
+	if err := s.write(ctx, path); err != nil {
+		return "", err
+	}
+
+	s.logger.Info("snapshot written to " + path)
+	if err := s.prune(); err != nil {
+		s.logger.With(slogx.Err(err)).Warn("failed to remove old snapshots")
+	}
+
+	return path, nil
+}
+
+// write writes a backup to the given path, the file is only created once the backup is complete.
+func (s *snapshotter) write(ctx context.Context, path string) error {
+	f, err := os.CreateTemp(s.dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+
+	err = s.engine.Backup(ctx, f)
+	if cerr := f.Close(); err == nil && cerr != nil {
+		err = fmt.Errorf("failed to write snapshot: %w", cerr)
+	}
+
+	if err == nil {
+		if err = os.Rename(f.Name(), path); err != nil {
+			err = fmt.Errorf("failed to write snapshot: %w", err)
+		}
+	}
+
+	if err != nil {
+		return errors.Join(err, os.Remove(f.Name()))
+	}
+
+	return nil
+}
+
+// prune removes the oldest snapshots exceeding the retention.
+func (s *snapshotter) prune() error {
+	snapshots, err := s.List()
+	if err != nil {
+		return err
+	}
+
+	errs := make([]error, 0)
+	for _, path := range snapshots[:max(len(snapshots)-s.retention, 0)] {
+		s.logger.Debug("removing snapshot " + path)
+		errs = append(errs, os.Remove(path))
+	}
+
+	return errors.Join(errs...)
+}
+
+// List returns the paths of the snapshots of the snapshot directory, oldest first.
+func (s *snapshotter) List() ([]string, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []string{}, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to list snapshots: %w", err)
+	}
+
+	snapshots := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || !strings.HasPrefix(name, filePrefix) || !strings.HasSuffix(name, fileSuffix) {
+			continue
+		}
+
+		snapshots = append(snapshots, filepath.Join(s.dir, name))
+	}
+
+	slices.Sort(snapshots)
+	return snapshots, nil
+}
@@ -7,34 +7,64 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/custom"
 	"github.com/blevesearch/bleve/v2/analysis/analyzer/keyword"
 	"github.com/blevesearch/bleve/v2/analysis/lang/en"
+	"github.com/blevesearch/bleve/v2/analysis/token/lowercase"
+	"github.com/blevesearch/bleve/v2/analysis/tokenizer/single"
 	"github.com/blevesearch/bleve/v2/mapping"
 
 	"github.com/SkYNewZ/gh-stars-search-engine/internal/engine"
 	"github.com/SkYNewZ/gh-stars-search-engine/internal/github"
 	ihttp "github.com/SkYNewZ/gh-stars-search-engine/internal/http"
+	"github.com/SkYNewZ/gh-stars-search-engine/internal/indexer"
 	"github.com/SkYNewZ/gh-stars-search-engine/internal/logging"
 	"github.com/SkYNewZ/gh-stars-search-engine/internal/slogx"
+	"github.com/SkYNewZ/gh-stars-search-engine/internal/snapshot"
 )
 
 //go:generate go run go-simpler.org/sloggen --config .slog.config.yaml --dir internal
 
 const (
-	indexPath         string = "ghs.belve"
-	indexingBatchSize int    = 100
+	indexingBatchSize int = 100
+
+	// indexPath is the default index storage directory, legacyIndexPath its former misspelled name kept when it exists
+	indexPath       string = "ghs.bleve"
+	legacyIndexPath string = "ghs.belve"
+
+	// snapshotPath and defaultSnapshotRetention are the default directory and number of scheduled snapshots
+	snapshotPath             string = "snapshots"
+	defaultSnapshotRetention int    = 7
+
+	// lowercaseKeywordAnalyzerName is the name of the analyzer indexing the whole value, lowercased
+	lowercaseKeywordAnalyzerName string = "keyword_lowercase"
 )
 
 func main() {
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt) // cancels running syncs on shutdown
+
 	logger := logging.New(slog.LevelDebug)
+	storagePath := getEnvOrDefault("BLEVE_STORAGE_PATH", getEnvOrDefault("BELVE_STORAGE_PATH", defaultStoragePath())) // former misspelled name
+
+	if len(os.Args) > 1 {
+		if err := runCommand(ctx, os.Args[1:], storagePath); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(-1)
+		}
+
+		return
+	}
+
 	traceClient := &http.Client{Transport: logging.NewLoggerTransport(logger.With(slogx.Component("http")))}
 
-	logger.Debug("creating GitHub graphQL client")
-	client, err := github.New(ctx, github.WithHTTPClient(traceClient), github.WithLogger(logger.With(slogx.Component("github")))) // default reads GITHUB_TOKEN
+	logger.Debug("creating GitHub graphQL clients")
+	clients, err := newGitHubClients(ctx, traceClient, logger.With(slogx.Component("github")))
 	if err != nil {
 		logger.With(slogx.Err(err)).Error("failed to create GitHub client")
 		os.Exit(-1)
@@ -47,7 +77,20 @@ func main() {
 		os.Exit(-1)
 	}
 
-	search, err := engine.New(getEnvOrDefault("BELVE_STORAGE_PATH", indexPath), logger.With(slogx.Component("engine")), mapper) // use the default mapping
+	relevance, err := relevanceFromEnv()
+	if err != nil {
+		logger.With(slogx.Err(err)).Error("invalid relevance configuration")
+		os.Exit(-1)
+	}
+
+	engineOpts, err := storageFromEnv()
+	if err != nil {
+		logger.With(slogx.Err(err)).Error("invalid storage configuration")
+		os.Exit(-1)
+	}
+
+	engineOpts = append(engineOpts, engine.WithRelevance(relevance))
+	search, err := engine.New(storagePath, logger.With(slogx.Component("engine")), mapper, engineOpts...)
 	if err != nil {
 		logger.With(slogx.Err(err)).Error("failed to create search engine")
 		os.Exit(-1)
@@ -61,44 +104,106 @@ func main() {
 		os.Exit(-1)
 	}
 
-	if _, err := scheduler.AddFunc(getEnvOrDefault("REFRESH_JOB_SCHEDULE", "0 */12 * * *"), index(ctx, client, search, schedulerLogger)); err != nil {
+	idx := indexer.New(clients, search, logger.With(slogx.Component("indexer")), indexingBatchSize)
+	if _, err := scheduler.AddFunc(getEnvOrDefault("REFRESH_JOB_SCHEDULE", "0 */12 * * *"), index(ctx, idx, false, schedulerLogger)); err != nil {
 		logger.With(slogx.Err(err)).Error("failed to add index job to scheduler")
 		os.Exit(-1)
 	}
 
+	if _, err := scheduler.AddFunc(getEnvOrDefault("FULL_REFRESH_JOB_SCHEDULE", "0 3 * * 0"), index(ctx, idx, true, schedulerLogger)); err != nil {
+		logger.With(slogx.Err(err)).Error("failed to add full index job to scheduler")
+		os.Exit(-1)
+	}
+
+	if schedule := os.Getenv("SNAPSHOT_JOB_SCHEDULE"); schedule != "" {
+		retention, err := strconv.Atoi(getEnvOrDefault("SNAPSHOT_RETENTION", strconv.Itoa(defaultSnapshotRetention)))
+		if err != nil || retention < 1 {
+			logger.Error("invalid SNAPSHOT_RETENTION, expected a positive number of snapshots")
+			os.Exit(-1)
+		}
+
+		snapshotter := snapshot.New(search, getEnvOrDefault("SNAPSHOT_PATH", snapshotPath), retention, logger.With(slogx.Component("snapshot")))
+		if _, err := scheduler.AddFunc(schedule, snapshotJob(ctx, snapshotter, schedulerLogger)); err != nil {
+			logger.With(slogx.Err(err)).Error("failed to add snapshot job to scheduler")
+			os.Exit(-1)
+		}
+	}
+
 	logger.Debug("configure HTTP server")
-	srv := ihttp.NewServer(logger.With(slogx.Component("server")), search, time.Minute)
+	srv := ihttp.NewServer(logger.With(slogx.Component("server")), search, idx, time.Minute, serverFromEnv()...)
 
 	go srv.Start()
 	go scheduler.Run()
 	if os.Getenv("NO_INITIAL_INDEX") == "" {
-		go index(ctx, client, search, logger)() // index once at startup
+		go index(ctx, idx, false, logger)() // index once at startup
 	}
 
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	<-c
+	<-ctx.Done()
+	stop() // a second interrupt kills the process
 
-	ctx, cancel := context.WithTimeout(ctx, time.Second*10)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
 	defer cancel()
 	srv.Stop(ctx)
 	scheduler.Stop()
 }
 
-// index periodically all stars.
-func index(ctx context.Context, g github.Client, s engine.Engine, logger *slog.Logger) func() {
+// index returns a job syncing the stars.
+// When full is false, only the stars added since the last sync are fetched.
+func index(ctx context.Context, idx indexer.Indexer, full bool, logger *slog.Logger) func() {
+	return func() {
+		if err := idx.Sync(ctx, full); err != nil {
+			logger.With(slogx.Err(err)).Error("failed to sync stars")
+		}
+	}
+}
+
+// snapshotJob returns a job writing a snapshot of the index.
+func snapshotJob(ctx context.Context, snapshotter snapshot.Snapshotter, logger *slog.Logger) func() {
 	return func() {
-		logger.Info("fetching stars")
-		repos := make([]engine.Indexable, 0)
-		for starredRepo := range g.GetStars(ctx) {
-			repos = append(repos, starredRepo.Repository)
+		if _, err := snapshotter.Snapshot(ctx); err != nil {
+			logger.With(slogx.Err(err)).Error("failed to snapshot index")
+		}
+	}
+}
+
+// newGitHubClients returns a GitHub client for each account listed in GITHUB_ACCOUNTS (comma separated logins).
+// An account uses the GITHUB_TOKEN_<LOGIN> token if set, GITHUB_TOKEN otherwise.
+// Without GITHUB_ACCOUNTS, the stars of the GITHUB_TOKEN owner are indexed.
+func newGitHubClients(ctx context.Context, httpClient *http.Client, logger *slog.Logger) ([]github.Client, error) {
+	opts := []github.Option{github.WithHTTPClient(httpClient), github.WithLogger(logger)}
+
+	accounts := os.Getenv("GITHUB_ACCOUNTS")
+	if accounts == "" {
+		client, err := github.New(ctx, opts...) // default reads GITHUB_TOKEN
+		if err != nil {
+			return nil, err
 		}
 
-		logger.Debug(fmt.Sprintf("indexing %d stars", len(repos)))
-		if err := s.BatchIndex(repos, indexingBatchSize); err != nil {
-			logger.With(slogx.Err(err)).Error("failed to index stars")
+		return []github.Client{client}, nil
+	}
+
+	clients := make([]github.Client, 0)
+	for _, login := range strings.Split(accounts, ",") {
+		login = strings.TrimSpace(login)
+		if login == "" {
+			continue
+		}
+
+		accountOpts := append(slices.Clip(opts), github.WithLogin(login), github.WithLogger(logger.With(slog.String("login", login))))
+		tokenEnv := "GITHUB_TOKEN_" + strings.ToUpper(strings.ReplaceAll(login, "-", "_"))
+		if token := os.Getenv(tokenEnv); token != "" {
+			accountOpts = append(accountOpts, github.WithToken(token))
 		}
+
+		client, err := github.New(ctx, accountOpts...)
+		if err != nil {
+			return nil, fmt.Errorf("account %s: %w", login, err)
+		}
+
+		clients = append(clients, client)
 	}
+
+	return clients, nil
 }
 
 func buildGitHubRepositoryIndexMapping() (mapping.IndexMapping, error) {
@@ -110,32 +215,251 @@ func buildGitHubRepositoryIndexMapping() (mapping.IndexMapping, error) {
 	keywordFieldMapping := bleve.NewTextFieldMapping()
 	keywordFieldMapping.Analyzer = keyword.Name
 
+	// a generic reusable mapping for identifiers, split into their parts
+	codeFieldMapping := bleve.NewTextFieldMapping()
+	codeFieldMapping.Analyzer = engine.CodeAnalyzerName
+
+	// generic reusable mappings for non text values
+	numericFieldMapping := bleve.NewNumericFieldMapping()
+	booleanFieldMapping := bleve.NewBooleanFieldMapping()
+	dateTimeFieldMapping := bleve.NewDateTimeFieldMapping()
+
 	// readme field mapping
 	readmeMapping := bleve.NewTextFieldMapping()
-	readmeMapping.Store = false // do not store the content of the field
+	readmeMapping.Store = true // stored to generate highlight fragments, never returned in the search fields
 	readmeMapping.Analyzer = en.AnalyzerName
 
 	repoMapping := bleve.NewDocumentMapping()
-	repoMapping.AddFieldMappingsAt("id", keywordFieldMapping)
-	repoMapping.AddFieldMappingsAt("name_with_owner", englishTextFieldMapping)
-	repoMapping.AddFieldMappingsAt("description", englishTextFieldMapping)
-	repoMapping.AddFieldMappingsAt("readme", readmeMapping)
+	addFieldMappingsAt(repoMapping, "id", keywordFieldMapping)
+	addFieldMappingsAt(repoMapping, "name_with_owner", codeFieldMapping)
+
+	// name words stemmed, so that "parser" matches "go-parsers", the code mapping keeps the identifiers parts
+	nameStemmedMapping := bleve.NewTextFieldMapping()
+	nameStemmedMapping.Analyzer = en.AnalyzerName
+	nameStemmedMapping.Name = "name_stemmed"
+	nameStemmedMapping.Store = false
+	addFieldMappingsAt(repoMapping, "name_with_owner", nameStemmedMapping)
+
+	addFieldMappingsAt(repoMapping, "description", englishTextFieldMapping)
+	addFieldMappingsAt(repoMapping, "owner.login", keywordFieldMapping)
+
+	// owner login split into its parts, the keyword mapping is kept for the facet
+	ownerWordsMapping := bleve.NewTextFieldMapping()
+	ownerWordsMapping.Analyzer = engine.CodeAnalyzerName
+	ownerWordsMapping.Name = "words" // owner.words
+	ownerWordsMapping.Store = false
+	addFieldMappingsAt(repoMapping, "owner.login", ownerWordsMapping)
+	addFieldMappingsAt(repoMapping, "readme", readmeMapping)
+	addFieldMappingsAt(repoMapping, "readme_headings", englishTextFieldMapping)
+
+	// readme code blocks, only searched when the field is explicitly queried (readme_code:...)
+	readmeCodeMapping := bleve.NewTextFieldMapping()
+	readmeCodeMapping.Analyzer = engine.CodeAnalyzerName
+	readmeCodeMapping.IncludeInAll = false
+	addFieldMappingsAt(repoMapping, "readme_code", readmeCodeMapping)
 
-	repoMapping.AddFieldMappingsAt("primary_language.id", keywordFieldMapping)
-	repoMapping.AddFieldMappingsAt("primaryLanguage.name", keywordFieldMapping)
-	repoMapping.AddFieldMappingsAt("primaryLanguage.color", keywordFieldMapping)
+	addFieldMappingsAt(repoMapping, "primary_language.id", keywordFieldMapping)
+	addFieldMappingsAt(repoMapping, "primary_language.name", keywordFieldMapping)
+	addFieldMappingsAt(repoMapping, "primary_language.color", keywordFieldMapping)
+
+	// language name lowercased, the keyword mapping is kept for the facet and the filter
+	languageLowercaseMapping := bleve.NewTextFieldMapping()
+	languageLowercaseMapping.Analyzer = lowercaseKeywordAnalyzerName
+	languageLowercaseMapping.Name = "name_lowercase" // primary_language.name_lowercase
+	languageLowercaseMapping.Store = false
+	languageLowercaseMapping.IncludeInAll = false
+	addFieldMappingsAt(repoMapping, "primary_language.name", languageLowercaseMapping)
+
+	addFieldMappingsAt(repoMapping, "topics", keywordFieldMapping)
+	addFieldMappingsAt(repoMapping, "stargazer_count", numericFieldMapping)
+	addFieldMappingsAt(repoMapping, "fork_count", numericFieldMapping)
+	addFieldMappingsAt(repoMapping, "license.key", keywordFieldMapping)
+	addFieldMappingsAt(repoMapping, "license.name", keywordFieldMapping)
+	addFieldMappingsAt(repoMapping, "license.spdx_id", keywordFieldMapping)
+	addFieldMappingsAt(repoMapping, "is_archived", booleanFieldMapping)
+	addFieldMappingsAt(repoMapping, "is_fork", booleanFieldMapping)
+	addFieldMappingsAt(repoMapping, "homepage_url", keywordFieldMapping)
+	addFieldMappingsAt(repoMapping, "pushed_at", dateTimeFieldMapping)
+	addFieldMappingsAt(repoMapping, "updated_at", dateTimeFieldMapping)
+	addFieldMappingsAt(repoMapping, "starred_at", dateTimeFieldMapping)
+
+	// star lists slugs, filtered with lists:<slug>, kept under their path for the query string to resolve the analyzer
+	listFieldMapping := bleve.NewTextFieldMapping()
+	listFieldMapping.Analyzer = keyword.Name
+	addFieldMappingsAt(repoMapping, "lists", listFieldMapping)
+
+	// users who starred the repository, the star date of each user is dynamically mapped under starred_at_by.<login>
+	addFieldMappingsAt(repoMapping, "starred_by", keywordFieldMapping)
+	addFieldMappingsAt(repoMapping, "starred_by_count", numericFieldMapping)
 
 	indexMapping := bleve.NewIndexMapping()
 	indexMapping.DefaultAnalyzer = en.AnalyzerName
 	indexMapping.DefaultMapping = repoMapping
+	if err := engine.AddCodeAnalyzer(indexMapping); err != nil {
+		return nil, fmt.Errorf("failed to add code analyzer: %w", err)
+	}
+
+	if err := indexMapping.AddCustomAnalyzer(lowercaseKeywordAnalyzerName, map[string]any{
+		"type":          custom.Name,
+		"tokenizer":     single.Name,
+		"token_filters": []any{lowercase.Name},
+	}); err != nil {
+		return nil, fmt.Errorf("failed to add lowercase keyword analyzer: %w", err)
+	}
 
 	if err := indexMapping.Validate(); err != nil {
 		return nil, fmt.Errorf("invalid mapping: %w", err)
 	}
 
+	// the mapped paths must match the JSON fields of the indexed documents
+	if err := engine.ValidateMapping(indexMapping, github.Repository{}); err != nil {
+		return nil, fmt.Errorf("invalid mapping: %w", err)
+	}
+
 	return indexMapping, nil
 }
 
+// addFieldMappingsAt adds the field mappings at the given dotted path of the document mapping.
+// Unlike mapping.DocumentMapping.AddFieldMappingsAt, the parents of a nested field get their own sub document mapping,
+// as the index looks up the mapping of a field one path element at a time.
+func addFieldMappingsAt(dm *mapping.DocumentMapping, path string, fms ...*mapping.FieldMapping) {
+	parents := strings.Split(path, ".")
+	name := parents[len(parents)-1]
+	for _, parent := range parents[:len(parents)-1] {
+		sub, ok := dm.Properties[parent]
+		if !ok {
+			sub = bleve.NewDocumentMapping()
+			dm.AddSubDocumentMapping(parent, sub)
+		}
+
+		dm = sub
+	}
+
+	dm.AddFieldMappingsAt(name, fms...)
+}
+
+// storageFromEnv returns the engine options storing the index according to the environment:
+// BLEVE_BACKEND is the storage backend (scorch or boltdb), BLEVE_IN_MEMORY=true keeps the index in memory
+// and BLEVE_PERSISTED_SNAPSHOTS is the number of previous states of the scorch backend kept on disk.
+// The scorch merge policy is tuned by BLEVE_MERGE_MAX_SEGMENTS_PER_TIER, BLEVE_MERGE_MAX_SEGMENT_SIZE,
+// BLEVE_MERGE_TIER_GROWTH, BLEVE_MERGE_SEGMENTS_PER_MERGE_TASK and BLEVE_MERGE_FLOOR_SEGMENT_SIZE, see engine.MergePolicy.
+func storageFromEnv() ([]engine.Option, error) {
+	opts := []engine.Option{engine.WithBackend(engine.Backend(getEnvOrDefault("BLEVE_BACKEND", string(engine.BackendScorch))))}
+	if os.Getenv("BLEVE_IN_MEMORY") == "true" {
+		opts = append(opts, engine.WithMemoryStorage())
+	}
+
+	count, err := positiveFromEnv[int]("BLEVE_PERSISTED_SNAPSHOTS")
+	if err != nil {
+		return nil, err
+	}
+
+	if count > 0 {
+		opts = append(opts, engine.WithPersistedSnapshots(count))
+	}
+
+	var policy engine.MergePolicy
+	if policy.MaxSegmentsPerTier, err = positiveFromEnv[int]("BLEVE_MERGE_MAX_SEGMENTS_PER_TIER"); err != nil {
+		return nil, err
+	}
+
+	if policy.MaxSegmentSize, err = positiveFromEnv[int64]("BLEVE_MERGE_MAX_SEGMENT_SIZE"); err != nil {
+		return nil, err
+	}
+
+	if policy.TierGrowth, err = positiveFromEnv[float64]("BLEVE_MERGE_TIER_GROWTH"); err != nil {
+		return nil, err
+	}
+
+	if policy.SegmentsPerMergeTask, err = positiveFromEnv[int]("BLEVE_MERGE_SEGMENTS_PER_MERGE_TASK"); err != nil {
+		return nil, err
+	}
+
+	if policy.FloorSegmentSize, err = positiveFromEnv[int64]("BLEVE_MERGE_FLOOR_SEGMENT_SIZE"); err != nil {
+		return nil, err
+	}
+
+	if policy != (engine.MergePolicy{}) {
+		opts = append(opts, engine.WithMergePolicy(policy))
+	}
+
+	return opts, nil
+}
+
+// positiveFromEnv returns the positive number set by the given environment variable, zero if unset.
+func positiveFromEnv[T int | int64 | float64](env string) (T, error) {
+	v := os.Getenv(env)
+	if v == "" {
+		return 0, nil
+	}
+
+	f, err := strconv.ParseFloat(v, 64)
+	if n := T(f); err != nil || float64(n) != f || n <= 0 {
+		return 0, fmt.Errorf("invalid %s %q, expected a positive number", env, v)
+	}
+
+	return T(f), nil
+}
+
+// defaultStoragePath returns the index storage directory used when none is configured,
+// the legacy directory when it exists so that existing indexes are not rebuilt.
+func defaultStoragePath() string {
+	if info, err := os.Stat(legacyIndexPath); err == nil && info.IsDir() {
+		return legacyIndexPath
+	}
+
+	return indexPath
+}
+
+// serverFromEnv returns the HTTP server options configured by the environment:
+// BACKUP_TOKEN enables the /api/backup endpoint, requests must send it as a bearer token.
+func serverFromEnv() []ihttp.Option {
+	opts := make([]ihttp.Option, 0)
+	if token := os.Getenv("BACKUP_TOKEN"); token != "" {
+		opts = append(opts, ihttp.WithBackupToken(token))
+	}
+
+	return opts
+}
+
+// relevanceFromEnv returns the search relevance, engine.DefaultRelevance tuned by the environment:
+// SEARCH_FIELD_BOOSTS replaces the field boosts (comma separated field:boost), also the fields searched by the fuzzy and prefix modes,
+// SEARCH_POPULARITY_BOOST and SEARCH_RECENCY_BOOST enable the popularity and recency boosts.
+func relevanceFromEnv() (engine.Relevance, error) {
+	relevance := engine.DefaultRelevance
+
+	if v := os.Getenv("SEARCH_FIELD_BOOSTS"); v != "" {
+		boosts := make(map[string]float64)
+		for _, pair := range strings.Split(v, ",") {
+			field, value, ok := strings.Cut(strings.TrimSpace(pair), ":")
+			boost, err := strconv.ParseFloat(value, 64)
+			if !ok || err != nil || field == "" || boost < 0 {
+				return relevance, fmt.Errorf("invalid field boost %q in SEARCH_FIELD_BOOSTS", pair)
+			}
+
+			boosts[field] = boost
+		}
+
+		relevance.FieldBoosts = boosts
+	}
+
+	for env, boost := range map[string]*float64{
+		"SEARCH_POPULARITY_BOOST": &relevance.Popularity,
+		"SEARCH_RECENCY_BOOST":    &relevance.Recency,
+	} {
+		if v := os.Getenv(env); v != "" {
+			value, err := strconv.ParseFloat(v, 64)
+			if err != nil || value < 0 {
+				return relevance, fmt.Errorf("invalid %s %q", env, v)
+			}
+
+			*boost = value
+		}
+	}
+
+	return relevance, nil
+}
+
 func getEnvOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/SkYNewZ/gh-stars-search-engine/internal/engine"
+	"github.com/SkYNewZ/gh-stars-search-engine/internal/github"
+)
+
+func TestBuildGitHubRepositoryIndexMapping(t *testing.T) {
+	// the mapped paths are validated against github.Repository while building the mapping
+	if _, err := buildGitHubRepositoryIndexMapping(); err != nil {
+		t.Fatalf("buildGitHubRepositoryIndexMapping() error = %v", err)
+	}
+}
+
+func TestRepositoryNameStemming(t *testing.T) {
+	m, err := buildGitHubRepositoryIndexMapping()
+	if err != nil {
+		t.Fatalf("buildGitHubRepositoryIndexMapping() error = %v", err)
+	}
+
+	e, err := engine.New("", nil, m, engine.WithMemoryStorage())
+	if err != nil {
+		t.Fatalf("engine.New() error = %v", err)
+	}
+
+	repos := []engine.Indexable{
+		&github.Repository{ID: "R_1", NameWithOwner: "octocat/tools", Description: "A JSON parser"},
+		&github.Repository{ID: "R_2", NameWithOwner: "octocat/go-parsers", Description: "Tools"},
+		&github.Repository{ID: "R_3", NameWithOwner: "octocat/linters", Description: "Tools"},
+	}
+
+	if err := e.BatchIndex(repos, 10); err != nil {
+		t.Fatalf("BatchIndex() error = %v", err)
+	}
+
+	q, err := engine.NewQuery("parser")
+	if err != nil {
+		t.Fatalf("NewQuery() error = %v", err)
+	}
+
+	results, err := e.Search(context.Background(), q)
+	if err != nil {
+		t.Fatalf("Search() error = %v", err)
+	}
+
+	// the stemmed name matches, ranked above the description match
+	ids := make([]string, 0, len(results.Hits))
+	for _, hit := range results.Hits {
+		ids = append(ids, hit.ID)
+	}
+
+	if want := []string{"R_2", "R_1"}; !slices.Equal(ids, want) {
+		t.Errorf("Search() = %v, want %v", ids, want)
+	}
+}
+
+func TestStorageFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr bool
+	}{
+		{name: "unset"},
+		{
+			name: "merge policy",
+			env: map[string]string{
+				"BLEVE_MERGE_MAX_SEGMENTS_PER_TIER": "5",
+				"BLEVE_MERGE_MAX_SEGMENT_SIZE":      "1000000",
+				"BLEVE_MERGE_TIER_GROWTH":           "4.5",
+			},
+		},
+		{name: "not a number", env: map[string]string{"BLEVE_MERGE_TIER_GROWTH": "fast"}, wantErr: true},
+		{name: "fractional count", env: map[string]string{"BLEVE_MERGE_SEGMENTS_PER_MERGE_TASK": "2.5"}, wantErr: true},
+		{name: "negative size", env: map[string]string{"BLEVE_MERGE_FLOOR_SEGMENT_SIZE": "-1"}, wantErr: true},
+		{name: "persisted snapshots", env: map[string]string{"BLEVE_PERSISTED_SNAPSHOTS": "3"}},
+		{name: "invalid persisted snapshots", env: map[string]string{"BLEVE_PERSISTED_SNAPSHOTS": "three"}, wantErr: true},
+		{name: "negative persisted snapshots", env: map[string]string{"BLEVE_PERSISTED_SNAPSHOTS": "-2"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			if _, err := storageFromEnv(); (err != nil) != tt.wantErr {
+				t.Errorf("storageFromEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDefaultStoragePath(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Getwd() error = %v", err)
+	}
+
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatalf("Chdir() error = %v", err)
+	}
+
+	t.Cleanup(func() { _ = os.Chdir(wd) })
+
+	if got := defaultStoragePath(); got != indexPath {
+		t.Errorf("defaultStoragePath() = %s, want %s", got, indexPath)
+	}
+
+	// an existing index keeps the former name
+	if err := os.Mkdir(legacyIndexPath, 0o755); err != nil {
+		t.Fatalf("Mkdir() error = %v", err)
+	}
+
+	if got := defaultStoragePath(); got != legacyIndexPath {
+		t.Errorf("defaultStoragePath() = %s, want %s", got, legacyIndexPath)
+	}
+}